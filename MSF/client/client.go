@@ -2,19 +2,37 @@ package flcli
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	cclient "github.com/rpcxio/rpcx-consul/client"
 	rclient "github.com/smallnest/rpcx/client"
-	"github.com/xiaolongdeng1990/forlife/MSF/consul"
+	"github.com/smallnest/rpcx/share"
+	flerrors "github.com/xiaolongdeng1990/forlife/MSF/errors"
+	fllog "github.com/xiaolongdeng1990/forlife/MSF/log"
+	flmetrics "github.com/xiaolongdeng1990/forlife/MSF/metrics"
+	"github.com/xiaolongdeng1990/forlife/MSF/registry"
+	fltrace "github.com/xiaolongdeng1990/forlife/MSF/trace"
 )
 
 // CallDesc RPC参数
 type CallDesc struct {
-	LocalServiceName string        // <非必填>本次请求主调服务名
-	ServiceName      string        // <必填>本次请求被调服务名, 对应toml配置文件中的一段
-	Timeout          time.Duration // <非必填>RPC超时时间
+	LocalServiceName string            // <非必填>本次请求主调服务名
+	ServiceName      string            // <必填>本次请求被调服务名, 对应toml配置文件中的一段
+	Timeout          time.Duration     // <非必填>RPC超时时间, ctx未带deadline时作为本次调用的deadline
+	ConnectTimeout   time.Duration     // <非必填>建连超时, 默认1s
+	ReadTimeout      time.Duration     // <非必填>连接读超时
+	WriteTimeout     time.Duration     // <非必填>连接写超时
+	FailMode         FailMode          // <非必填>失败处理方式, 默认failtry
+	SelectMode       SelectMode        // <非必填>负载均衡方式, 默认random
+	Retries          *int              // <非必填>failtry/failover的重试次数, nil时取[[Client]]配置, 默认3; Int(0)不重试, 链中有Retry时不生效
+	HashKey          string            // <非必填>consistenthash时作为key的请求字段名
+	HashKeyFunc      HashKeyFunc       // <非必填>自定义哈希key, 优先于HashKey
+	Registry         registry.Registry // <非必填>服务发现, 默认使用registry.Default()
+	Interceptors     []Interceptor     // <非必填>本服务专用的拦截器, 在全局拦截器(Use)内层执行
 }
 
 type ServiceInfo struct {
@@ -24,49 +42,315 @@ type ServiceInfo struct {
 }
 
 type FlClient struct {
-	RpcCli rclient.XClient
+	RpcCli rclient.XClient // 创建时的rpcx客户端, 配置热更新后DoRequest可能改用新建的
 
 	SvrInfo ServiceInfo
+
+	desc      CallDesc // 调用方传入的CallDesc, [[Client]]配置变化时重新合并
+	discovery rclient.ServiceDiscovery
+	route     atomic.Pointer[route]
+	invoker   Invoker
+	retrying  bool           // 拦截器链中有Retry, rpcx不再重试
+	managed   *managedClient // 非空表示由GetClient共享创建
+	err       error          // NewClient创建失败的原因, DoRequest时返回
+	unwatch   func()         // 注销服务发现指标
+}
+
+// route 合并配置后的调用参数, 配置变化时整体替换
+type route struct {
+	cli      rclient.XClient
+	timeout  time.Duration
+	failMode FailMode
+	desc     CallDesc
 }
 
+// retireDelay 配置变化替换下来的rpcx客户端延迟关闭, 等进行中的请求结束
+const retireDelay = time.Minute
+
+// NewClient 创建被调服务的客户端, 同NewClientE但不返回错误:
+// CallDesc参数非法时使用默认的fail/select mode, 其余错误在DoRequest时返回
 func NewClient(callDesc CallDesc) *FlClient {
-	// get consul addr to-do
-	consulAddr := consul.GetConsulAddr()
-
-	// parse svr_addr
-	flC := &FlClient{}
-	flC.ParseSvrInfo(callDesc.ServiceName)
-	svrDiscovery, _ := cclient.NewConsulDiscovery(
-		flC.SvrInfo.SvrBasePath,
-		flC.SvrInfo.SvrName,
-		[]string{consulAddr},
-		nil)
-	flC.RpcCli = rclient.NewXClient(
-		flC.SvrInfo.SvrName,
-		rclient.Failtry,
-		rclient.RandomSelect,
-		svrDiscovery,
-		rclient.DefaultOption)
+	flC, err := NewClientE(callDesc)
+	if errors.Is(err, ErrInvalidCallDesc) {
+		logger.Error("invalid calldesc, use default fail/select mode", "service", callDesc.ServiceName, "err", err)
+		callDesc.FailMode, callDesc.SelectMode, callDesc.Retries = Failtry, RandomSelect, nil
+		flC, err = NewClientE(callDesc)
+	}
+	if err != nil {
+		logger.Error("new client failed", "service", callDesc.ServiceName, "err", err)
+		return &FlClient{err: err}
+	}
 	return flC
 }
 
+// NewClientE 创建被调服务的客户端, CallDesc未填写的字段取自flcli.Init/Watch加载的[[Client]]配置.
+// ServiceName格式为 basePath.SvrName.Interface, 错误可用errors.Is与ErrXXX比较
+func NewClientE(callDesc CallDesc) (*FlClient, error) {
+	svrInfo, err := ParseServiceName(callDesc.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	desc := callDesc
+	callDesc, err = mergeCallDesc(callDesc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidCallDesc, callDesc.ServiceName, err)
+	}
+
+	reg := callDesc.Registry
+	if reg == nil {
+		if reg, err = registry.Default(); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrDiscovery, callDesc.ServiceName, err)
+		}
+	}
+	svrDiscovery, err := registry.NewDiscovery(
+		reg,
+		svrInfo.SvrBasePath,
+		svrInfo.SvrName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrDiscovery, callDesc.ServiceName, err)
+	}
+
+	interceptors := chainInterceptors(callDesc.Interceptors)
+	flC := &FlClient{SvrInfo: svrInfo, desc: desc, discovery: svrDiscovery, retrying: hasRetry(interceptors)}
+	flC.RpcCli = flC.newXClient(callDesc)
+	flC.route.Store(&route{cli: flC.RpcCli, timeout: callDesc.Timeout, failMode: callDesc.FailMode, desc: callDesc})
+	flC.invoker = buildInvoker(flC.call, interceptors)
+	flC.unwatch = flmetrics.WatchDiscovery(svrInfo.SvrName, func() int {
+		return len(svrDiscovery.GetServices())
+	})
+	track(flC)
+	return flC, nil
+}
+
+func (f *FlClient) newXClient(callDesc CallDesc) rclient.XClient {
+	option := newOption(callDesc)
+	if f.retrying {
+		option.Retries = 0
+	}
+	cli := rclient.NewXClient(
+		f.SvrInfo.SvrName,
+		failModes[callDesc.FailMode],
+		selectModes[callDesc.SelectMode],
+		f.discovery,
+		option)
+	if callDesc.SelectMode == ConsistentHash {
+		cli.SetSelector(newHashKeySelector(callDesc))
+	}
+	return cli
+}
+
+// current 当前的调用参数, 未通过NewClient创建时使用RpcCli
+func (f *FlClient) current() *route {
+	if r := f.route.Load(); r != nil {
+		return r
+	}
+	return &route{cli: f.RpcCli}
+}
+
+// applyConfig 按最新的[[Client]]配置重新合并CallDesc; 只有Timeout变化时沿用rpcx客户端,
+// 负载均衡、失败处理、重试与连接超时变化时新建, 旧的延迟关闭. 合并失败保留原参数
+func (f *FlClient) applyConfig() {
+	old := f.route.Load()
+	if old == nil {
+		return
+	}
+	callDesc, err := mergeCallDesc(f.desc)
+	if err != nil {
+		logger.Error("apply client cfg failed, keep old", "service", f.desc.ServiceName, "err", err)
+		return
+	}
+	if sameConn(old.desc, callDesc) {
+		if old.timeout != callDesc.Timeout {
+			f.route.Store(&route{cli: old.cli, timeout: callDesc.Timeout, failMode: old.failMode, desc: callDesc})
+			logger.Info("client timeout changed", "service", f.desc.ServiceName, "timeout", callDesc.Timeout.String())
+		}
+		return
+	}
+	f.route.Store(&route{cli: f.newXClient(callDesc), timeout: callDesc.Timeout, failMode: callDesc.FailMode, desc: callDesc})
+	time.AfterFunc(retireDelay, func() { old.cli.Close() })
+	logger.Info("client cfg changed", "service", f.desc.ServiceName,
+		"failMode", callDesc.FailMode, "selectMode", callDesc.SelectMode, "retries", *callDesc.Retries)
+}
+
+// sameConn 是否可以沿用同一个rpcx客户端
+func sameConn(a, b CallDesc) bool {
+	return a.ConnectTimeout == b.ConnectTimeout && a.ReadTimeout == b.ReadTimeout && a.WriteTimeout == b.WriteTimeout &&
+		a.FailMode == b.FailMode && a.SelectMode == b.SelectMode && *a.Retries == *b.Retries && a.HashKey == b.HashKey
+}
+
+// newOption 将CallDesc中的超时映射到rpcx的Option
+func newOption(callDesc CallDesc) rclient.Option {
+	option := rclient.DefaultOption
+	option.Retries = *callDesc.Retries
+	if callDesc.ConnectTimeout > 0 {
+		option.ConnectTimeout = callDesc.ConnectTimeout
+	}
+	// rpcx客户端只有一个连接级的读写deadline(IdleTimeout), 取两者中较大的
+	idle := callDesc.ReadTimeout
+	if callDesc.WriteTimeout > idle {
+		idle = callDesc.WriteTimeout
+	}
+	if idle > 0 {
+		option.IdleTimeout = idle
+	}
+	return option
+}
+
+// Close 关闭客户端; GetClient取得的共享客户端等同于Release
 func (f *FlClient) Close() {
-	f.RpcCli.Close()
+	if f.managed != nil {
+		f.Release()
+		return
+	}
+	f.close()
 }
 
+// close 关闭连接与服务发现, 注销服务发现指标; rpcx的XClient.Close不会关闭ServiceDiscovery
+func (f *FlClient) close() {
+	untrack(f)
+	if f.unwatch != nil {
+		f.unwatch()
+	}
+	if cli := f.current().cli; cli != nil {
+		cli.Close()
+	}
+	if f.discovery != nil {
+		f.discovery.Close()
+	}
+}
+
+// DoRequest 经过拦截器链后调用被调服务.
+// ctx未带deadline时使用CallDesc.Timeout, deadline会随请求传给服务端, 重试共用同一预算.
+// 调用失败返回*flerrors.Error, 可用flerrors.CodeOf取错误码
 func (f *FlClient) DoRequest(ctx context.Context, req interface{}, rsp interface{}) error {
-	return f.RpcCli.Call(ctx, f.SvrInfo.InterfaceName, req, rsp)
+	if f.err != nil {
+		return f.err
+	}
+	if timeout := f.current().timeout; timeout > 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+	if f.invoker == nil {
+		// 未通过NewClient创建时没有拦截器
+		f.invoker = f.call
+	}
+	serviceMethod := f.SvrInfo.SvrName + "." + f.SvrInfo.InterfaceName
+	// 链路上下文与请求ID随请求元数据传给服务端, 请求ID沿用上游的, 入口处新生成
+	ctx, span := fltrace.StartSpan(ctx, serviceMethod, fltrace.KindClient)
+	sc := span.SpanContext()
+	requestID := fllog.ContextValue(ctx, fllog.FieldRequestID)
+	if len(requestID) == 0 {
+		requestID = fltrace.NewRequestID()
+	}
+	ctx = WithMetadata(ctx, map[string]string{
+		fltrace.TraceparentKey: sc.Traceparent(),
+		fltrace.RequestIDKey:   requestID,
+	})
+	ctx = fllog.NewContext(ctx,
+		fllog.FieldTraceID, sc.TraceID.String(),
+		fllog.FieldSpanID, sc.SpanID.String(),
+		fllog.FieldRequestID, requestID)
+
+	done := flmetrics.Client.Begin(f.SvrInfo.SvrName, f.SvrInfo.InterfaceName)
+	err := f.invoker(ctx, serviceMethod, req, rsp)
+	done(err)
+	span.SetError(err)
+	span.End()
+	return err
+}
+
+// call 拦截器链最内层的实际调用
+func (f *FlClient) call(ctx context.Context, serviceMethod string, req, rsp interface{}) error {
+	// rpcx只在ctx带有ResMetaDataKey时回填响应元数据, 错误码从中还原
+	resMeta, ok := ctx.Value(share.ResMetaDataKey).(map[string]string)
+	if !ok {
+		resMeta = make(map[string]string)
+		ctx = context.WithValue(ctx, share.ResMetaDataKey, resMeta)
+	}
+	if _, ok := ctx.Value(share.ContextTagsLock).(*sync.Mutex); !ok {
+		ctx = context.WithValue(ctx, share.ContextTagsLock, &sync.Mutex{})
+	}
+
+	var err error
+	r := f.current()
+	switch r.failMode {
+	case Forking:
+		err = r.cli.Fork(ctx, f.SvrInfo.InterfaceName, req, rsp)
+	case Broadcast:
+		err = r.cli.Broadcast(ctx, f.SvrInfo.InterfaceName, req, rsp)
+	default:
+		err = r.cli.Call(ctx, f.SvrInfo.InterfaceName, req, rsp)
+	}
+	if err == nil {
+		return nil
+	}
+	locker := ctx.Value(share.ContextTagsLock).(*sync.Mutex)
+	locker.Lock()
+	defer locker.Unlock()
+	return callError(err, resMeta)
 }
 
+// callError 把rpcx返回的错误转为flerrors.Error: 服务端回传了错误码的按码还原,
+// 未带码的服务端错误为Unknown, ctx超时/取消为Timeout/Canceled, 其余视为下游不可用
+func callError(err error, resMeta map[string]string) error {
+	if e := flerrors.FromMetadata(resMeta); e != nil {
+		return e
+	}
+	var svcErr rclient.ServiceError
+	switch {
+	case errors.As(err, &svcErr):
+		return flerrors.Wrap(err, flerrors.Unknown, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return flerrors.Wrap(err, flerrors.Timeout, err.Error())
+	case errors.Is(err, context.Canceled):
+		return flerrors.Wrap(err, flerrors.Canceled, err.Error())
+	}
+	var flErr *flerrors.Error
+	if errors.As(err, &flErr) {
+		return err
+	}
+	return flerrors.Wrap(err, flerrors.Unavailable, err.Error())
+}
+
+// ParseSvrInfo 解析ServiceName到SvrInfo, 格式非法时只填充能解析出的部分
 func (f *FlClient) ParseSvrInfo(serviceName string) {
 	vecSplit := strings.Split(serviceName, ".")
-	if len(vecSplit) >= 0 {
+	if len(vecSplit) >= 1 {
 		f.SvrInfo.SvrBasePath = vecSplit[0]
 	}
-	if len(vecSplit) >= 1 {
+	if len(vecSplit) >= 2 {
 		f.SvrInfo.SvrName = vecSplit[1]
 	}
-	if len(vecSplit) >= 2 {
+	if len(vecSplit) >= 3 {
 		f.SvrInfo.InterfaceName = vecSplit[2]
 	}
 }
+
+// ParseServiceName 解析并校验 basePath.SvrName.Interface 格式的服务名, basePath可带前导/
+func ParseServiceName(serviceName string) (ServiceInfo, error) {
+	vecSplit := strings.Split(serviceName, ".")
+	if len(vecSplit) > 3 {
+		return ServiceInfo{}, fmt.Errorf("%w: %q has %d parts, want basePath.SvrName.Interface",
+			ErrInvalidServiceName, serviceName, len(vecSplit))
+	}
+	for len(vecSplit) < 3 {
+		vecSplit = append(vecSplit, "")
+	}
+	info := ServiceInfo{
+		SvrBasePath:   vecSplit[0],
+		SvrName:       vecSplit[1],
+		InterfaceName: vecSplit[2],
+	}
+	switch {
+	case len(strings.TrimPrefix(info.SvrBasePath, "/")) == 0:
+		return info, fmt.Errorf("%w: %q", ErrMissingBasePath, serviceName)
+	case len(info.SvrName) == 0:
+		return info, fmt.Errorf("%w: %q", ErrMissingSvrName, serviceName)
+	case len(info.InterfaceName) == 0:
+		return info, fmt.Errorf("%w: %q", ErrMissingInterface, serviceName)
+	}
+	return info, nil
+}
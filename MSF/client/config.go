@@ -0,0 +1,312 @@
+package flcli
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	rclient "github.com/smallnest/rpcx/client"
+	"github.com/xiaolongdeng1990/forlife/MSF/config"
+)
+
+// FailMode 调用失败时的处理方式
+type FailMode string
+
+const (
+	Failtry    FailMode = "failtry"    // 在同一节点上重试, 默认
+	Failover   FailMode = "failover"   // 换节点重试, 适合幂等读
+	Failfast   FailMode = "failfast"   // 失败立即返回, 适合写
+	Failbackup FailMode = "failbackup" // 超过BackupLatency未返回则并发请求另一节点
+	Forking    FailMode = "forking"    // 并发请求所有节点, 取最先成功的
+	Broadcast  FailMode = "broadcast"  // 请求所有节点, 全部成功才算成功
+)
+
+// SelectMode 负载均衡方式
+type SelectMode string
+
+const (
+	RandomSelect       SelectMode = "random" // 默认
+	RoundRobin         SelectMode = "roundrobin"
+	WeightedRoundRobin SelectMode = "weightedroundrobin" // 按服务元数据中的weight
+	ConsistentHash     SelectMode = "consistenthash"     // 按HashKey做一致性哈希
+	Latency            SelectMode = "latency"            // 按ping延迟加权
+)
+
+// Int 返回n的指针, 用于CallDesc.Retries, 如 Retries: flcli.Int(0) 关闭重试
+func Int(n int) *int {
+	return &n
+}
+
+// HashKeyFunc 从请求中取一致性哈希的key, 返回空串时退化为按请求内容哈希
+type HashKeyFunc func(ctx context.Context, req interface{}) string
+
+var failModes = map[FailMode]rclient.FailMode{
+	Failtry:    rclient.Failtry,
+	Failover:   rclient.Failover,
+	Failfast:   rclient.Failfast,
+	Failbackup: rclient.Failbackup,
+	Forking:    rclient.Failfast, // Fork/Broadcast自身已覆盖所有节点, 不再重试
+	Broadcast:  rclient.Failfast,
+}
+
+var selectModes = map[SelectMode]rclient.SelectMode{
+	RandomSelect:       rclient.RandomSelect,
+	RoundRobin:         rclient.RoundRobin,
+	WeightedRoundRobin: rclient.WeightedRoundRobin,
+	ConsistentHash:     rclient.SelectByUser, // 使用hashKeySelector, 支持自定义HashKey
+	Latency:            rclient.WeightedICMP,
+}
+
+// ServiceCfg 一个被调服务的配置, 对应toml中的一段[[Client]]
+type ServiceCfg struct {
+	ServiceName    string          // basePath.SvrName 或 basePath.SvrName.Interface
+	Timeout        config.Duration `default:"0s"`
+	ConnectTimeout config.Duration `default:"1s"`
+	ReadTimeout    config.Duration `default:"0s"`
+	WriteTimeout   config.Duration `default:"0s"`
+	FailMode       string          `default:"failtry"`
+	SelectMode     string          `default:"random"`
+	Retries        int             `default:"3"`
+	HashKey        string          `default:""` // 一致性哈希使用的请求字段名
+}
+
+// ClientCfg 客户端配置
+type ClientCfg struct {
+	Client []ServiceCfg
+}
+
+var (
+	svcCfgMu sync.RWMutex
+	svcCfgs  = map[string]ServiceCfg{}
+
+	// live NewClient创建且未关闭的客户端, 配置变化时逐个更新
+	liveMu     sync.Mutex
+	live       = map[*FlClient]struct{}{}
+	cfgWatcher *config.Watcher[ClientCfg]
+)
+
+// Init 加载被调服务配置, NewClient时按ServiceName合并到CallDesc
+func Init(cfg string) error {
+	cliCfg := ClientCfg{}
+	if _, err := config.Load(&cliCfg, cfg); err != nil {
+		return fmt.Errorf("load client cfg %s: %w", cfg, err)
+	}
+	return setServiceCfgs(cliCfg)
+}
+
+// Watch 同Init, 之后配置文件修改时更新[[Client]]配置, 已创建客户端的超时、负载均衡、失败处理等随之生效.
+// CallDesc中显式填写的字段不受配置影响; 重复调用时替换之前的监听
+func Watch(cfg string) error {
+	cliCfg := ClientCfg{}
+	w, err := config.Watch(cfg, &cliCfg, onClientCfgChange, func(err error) {
+		logger.Error("watch client cfg error, keep old", "cfg", cfg, "err", err)
+	})
+	if err != nil {
+		return fmt.Errorf("watch client cfg %s: %w", cfg, err)
+	}
+	if err := setServiceCfgs(cliCfg); err != nil {
+		w.Close()
+		return err
+	}
+
+	liveMu.Lock()
+	if cfgWatcher != nil {
+		cfgWatcher.Close()
+	}
+	cfgWatcher = w
+	liveMu.Unlock()
+	return nil
+}
+
+func onClientCfgChange(_, new *ClientCfg) {
+	if err := setServiceCfgs(*new); err != nil {
+		logger.Error("reload client cfg failed, keep old", "err", err)
+		return
+	}
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	for f := range live {
+		f.applyConfig()
+	}
+	logger.Info("client cfg reloaded", "services", len(new.Client), "clients", len(live))
+}
+
+func setServiceCfgs(cliCfg ClientCfg) error {
+	cfgs := make(map[string]ServiceCfg, len(cliCfg.Client))
+	for _, c := range cliCfg.Client {
+		if err := c.validate(); err != nil {
+			return err
+		}
+		cfgs[strings.TrimPrefix(c.ServiceName, "/")] = c
+	}
+
+	svcCfgMu.Lock()
+	svcCfgs = cfgs
+	svcCfgMu.Unlock()
+	return nil
+}
+
+func track(f *FlClient) {
+	liveMu.Lock()
+	live[f] = struct{}{}
+	liveMu.Unlock()
+}
+
+// untrack 与配置更新互斥, 关闭后不会再新建rpcx客户端
+func untrack(f *FlClient) {
+	liveMu.Lock()
+	delete(live, f)
+	liveMu.Unlock()
+}
+
+func (c ServiceCfg) validate() error {
+	if len(c.ServiceName) == 0 {
+		return fmt.Errorf("client cfg ServiceName empty")
+	}
+	if _, ok := failModes[FailMode(strings.ToLower(c.FailMode))]; c.FailMode != "" && !ok {
+		return fmt.Errorf("client cfg %s: not support FailMode %s", c.ServiceName, c.FailMode)
+	}
+	if _, ok := selectModes[SelectMode(strings.ToLower(c.SelectMode))]; c.SelectMode != "" && !ok {
+		return fmt.Errorf("client cfg %s: not support SelectMode %s", c.ServiceName, c.SelectMode)
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("client cfg %s: Retries %d < 0", c.ServiceName, c.Retries)
+	}
+	return nil
+}
+
+// lookupServiceCfg 先按完整ServiceName查找, 再按basePath.SvrName查找
+func lookupServiceCfg(serviceName string) (ServiceCfg, bool) {
+	svcCfgMu.RLock()
+	defer svcCfgMu.RUnlock()
+
+	name := strings.TrimPrefix(serviceName, "/")
+	if c, ok := svcCfgs[name]; ok {
+		return c, true
+	}
+	if idx := strings.LastIndex(name, "."); idx > 0 {
+		if c, ok := svcCfgs[name[:idx]]; ok {
+			return c, true
+		}
+	}
+	return ServiceCfg{}, false
+}
+
+// mergeCallDesc CallDesc中未填写的字段使用配置文件中的值, 最后补默认值并校验
+func mergeCallDesc(callDesc CallDesc) (CallDesc, error) {
+	if c, ok := lookupServiceCfg(callDesc.ServiceName); ok {
+		if callDesc.Timeout == 0 {
+			callDesc.Timeout = c.Timeout.Duration()
+		}
+		if callDesc.ConnectTimeout == 0 {
+			callDesc.ConnectTimeout = c.ConnectTimeout.Duration()
+		}
+		if callDesc.ReadTimeout == 0 {
+			callDesc.ReadTimeout = c.ReadTimeout.Duration()
+		}
+		if callDesc.WriteTimeout == 0 {
+			callDesc.WriteTimeout = c.WriteTimeout.Duration()
+		}
+		if callDesc.FailMode == "" {
+			callDesc.FailMode = FailMode(c.FailMode)
+		}
+		if callDesc.SelectMode == "" {
+			callDesc.SelectMode = SelectMode(c.SelectMode)
+		}
+		if callDesc.Retries == nil {
+			callDesc.Retries = Int(c.Retries)
+		}
+		if callDesc.HashKey == "" {
+			callDesc.HashKey = c.HashKey
+		}
+	}
+
+	callDesc.FailMode = FailMode(strings.ToLower(string(callDesc.FailMode)))
+	callDesc.SelectMode = SelectMode(strings.ToLower(string(callDesc.SelectMode)))
+	if callDesc.FailMode == "" {
+		callDesc.FailMode = Failtry
+	}
+	if callDesc.SelectMode == "" {
+		callDesc.SelectMode = RandomSelect
+	}
+	if callDesc.Retries == nil {
+		// 没有[[Client]]配置时与其default tag一致
+		def := ServiceCfg{}
+		if err := config.SetDefaults(&def); err != nil {
+			return callDesc, err
+		}
+		callDesc.Retries = Int(def.Retries)
+	}
+
+	if _, ok := failModes[callDesc.FailMode]; !ok {
+		return callDesc, fmt.Errorf("not support FailMode %s", callDesc.FailMode)
+	}
+	if _, ok := selectModes[callDesc.SelectMode]; !ok {
+		return callDesc, fmt.Errorf("not support SelectMode %s", callDesc.SelectMode)
+	}
+	if *callDesc.Retries < 0 {
+		return callDesc, fmt.Errorf("Retries %d < 0", *callDesc.Retries)
+	}
+	return callDesc, nil
+}
+
+// hashKeySelector 一致性哈希, key由HashKeyFunc或请求中HashKey字段得到
+type hashKeySelector struct {
+	mu      sync.RWMutex
+	servers []string
+	keyFunc HashKeyFunc
+}
+
+func newHashKeySelector(callDesc CallDesc) *hashKeySelector {
+	keyFunc := callDesc.HashKeyFunc
+	if keyFunc == nil && len(callDesc.HashKey) > 0 {
+		keyFunc = fieldHashKey(callDesc.HashKey)
+	}
+	return &hashKeySelector{keyFunc: keyFunc}
+}
+
+func (s *hashKeySelector) Select(ctx context.Context, servicePath, serviceMethod string, args interface{}) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.servers) == 0 {
+		return ""
+	}
+	var key interface{} = args
+	if s.keyFunc != nil {
+		if k := s.keyFunc(ctx, args); len(k) > 0 {
+			key = k
+		}
+	}
+	return s.servers[rclient.JumpConsistentHash(len(s.servers), servicePath, serviceMethod, key)]
+}
+
+func (s *hashKeySelector) UpdateServer(servers map[string]string) {
+	ss := make([]string, 0, len(servers))
+	for k := range servers {
+		ss = append(ss, k)
+	}
+	sort.Strings(ss)
+
+	s.mu.Lock()
+	s.servers = ss
+	s.mu.Unlock()
+}
+
+// fieldHashKey 取请求结构体中名为name的字段作为哈希key
+func fieldHashKey(name string) HashKeyFunc {
+	return func(ctx context.Context, req interface{}) string {
+		v := reflect.Indirect(reflect.ValueOf(req))
+		if v.Kind() != reflect.Struct {
+			return ""
+		}
+		field := v.FieldByName(name)
+		if !field.IsValid() || !field.CanInterface() {
+			return ""
+		}
+		return fmt.Sprint(field.Interface())
+	}
+}
@@ -0,0 +1,67 @@
+package flcli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xiaolongdeng1990/forlife/MSF/config"
+)
+
+func TestMergeCallDescRetries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.toml")
+	data := `[[Client]]
+ServiceName = "demo.Math"
+FailMode = "failfast"
+Retries = 0
+
+[[Client]]
+ServiceName = "demo.Order"
+Retries = 5
+
+[[Client]]
+ServiceName = "demo.User"
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cliCfg ClientCfg
+	if _, err := config.Load(&cliCfg, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := setServiceCfgs(cliCfg); err != nil {
+		t.Fatal(err)
+	}
+	defer setServiceCfgs(ClientCfg{})
+
+	tests := []struct {
+		name    string
+		desc    CallDesc
+		want    int
+		wantErr bool
+	}{
+		{name: "cfg zero", desc: CallDesc{ServiceName: "demo.Math"}, want: 0},
+		{name: "cfg interface", desc: CallDesc{ServiceName: "demo.Order.Create"}, want: 5},
+		{name: "cfg default tag", desc: CallDesc{ServiceName: "demo.User"}, want: 3},
+		{name: "no cfg", desc: CallDesc{ServiceName: "demo.None"}, want: 3},
+		{name: "desc zero over cfg", desc: CallDesc{ServiceName: "demo.Order", Retries: Int(0)}, want: 0},
+		{name: "desc over cfg", desc: CallDesc{ServiceName: "demo.Math", Retries: Int(2)}, want: 2},
+		{name: "negative", desc: CallDesc{ServiceName: "demo.None", Retries: Int(-1)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := mergeCallDesc(tt.desc)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got.Retries == nil || *got.Retries != tt.want {
+			t.Errorf("%s: Retries = %v, want %d", tt.name, got.Retries, tt.want)
+		}
+		if n := newOption(got).Retries; n != tt.want {
+			t.Errorf("%s: option.Retries = %d, want %d", tt.name, n, tt.want)
+		}
+	}
+}
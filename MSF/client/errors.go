@@ -0,0 +1,22 @@
+package flcli
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NewClientE返回的错误, 可用errors.Is判断
+var (
+	// ErrInvalidServiceName ServiceName不是 basePath.SvrName.Interface 格式
+	ErrInvalidServiceName = errors.New("invalid service name")
+	// ErrMissingBasePath ServiceName缺少basePath
+	ErrMissingBasePath = fmt.Errorf("%w: basePath missing", ErrInvalidServiceName)
+	// ErrMissingSvrName ServiceName缺少SvrName
+	ErrMissingSvrName = fmt.Errorf("%w: SvrName missing", ErrInvalidServiceName)
+	// ErrMissingInterface ServiceName缺少接口名
+	ErrMissingInterface = fmt.Errorf("%w: Interface missing", ErrInvalidServiceName)
+	// ErrInvalidCallDesc FailMode/SelectMode/Retries等参数非法
+	ErrInvalidCallDesc = errors.New("invalid calldesc")
+	// ErrDiscovery 创建服务发现失败
+	ErrDiscovery = errors.New("service discovery failed")
+)
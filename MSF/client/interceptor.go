@@ -0,0 +1,237 @@
+package flcli
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/smallnest/rpcx/share"
+	flerrors "github.com/xiaolongdeng1990/forlife/MSF/errors"
+	fllog "github.com/xiaolongdeng1990/forlife/MSF/log"
+)
+
+// logger flcli的模块logger, 可用 LogConf.Modules 中的 client = "DEBUG" 单独调整级别
+var logger = fllog.Named("client")
+
+// Invoker 实际发起RPC调用的函数
+type Invoker func(ctx context.Context, serviceMethod string, req, rsp interface{}) error
+
+// Interceptor 客户端拦截器, 调用next进入下一个拦截器, 最后一个next即RpcCli.Call
+type Interceptor func(ctx context.Context, serviceMethod string, req, rsp interface{}, next Invoker) error
+
+// ErrCircuitOpen 熔断打开期间直接返回该错误, 错误码为Unavailable
+var ErrCircuitOpen = flerrors.New(flerrors.Unavailable, "circuit breaker is open")
+
+var (
+	globalMu           sync.RWMutex
+	globalInterceptors []Interceptor
+)
+
+// Use 追加全局拦截器, 对之后创建的FlClient生效, 在CallDesc.Interceptors的外层
+func Use(interceptors ...Interceptor) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalInterceptors = append(globalInterceptors, interceptors...)
+}
+
+// chainInterceptors 按 全局 -> CallDesc 的顺序排列
+func chainInterceptors(local []Interceptor) []Interceptor {
+	globalMu.RLock()
+	interceptors := make([]Interceptor, 0, len(globalInterceptors)+len(local))
+	interceptors = append(interceptors, globalInterceptors...)
+	globalMu.RUnlock()
+	return append(interceptors, local...)
+}
+
+// buildInvoker 把拦截器串到invoker外层, 先排的在外层
+func buildInvoker(invoker Invoker, interceptors []Interceptor) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, serviceMethod string, req, rsp interface{}) error {
+			return interceptor(ctx, serviceMethod, req, rsp, next)
+		}
+	}
+	return invoker
+}
+
+// WithMetadata 返回携带请求元数据的ctx, 不修改调用方原有的元数据
+func WithMetadata(ctx context.Context, kv map[string]string) context.Context {
+	meta := make(map[string]string, len(kv))
+	if old, ok := ctx.Value(share.ReqMetaDataKey).(map[string]string); ok {
+		for k, v := range old {
+			meta[k] = v
+		}
+	}
+	for k, v := range kv {
+		meta[k] = v
+	}
+	return context.WithValue(ctx, share.ReqMetaDataKey, meta)
+}
+
+// Logging 打印每次调用的req/rsp/耗时
+func Logging() Interceptor {
+	return func(ctx context.Context, serviceMethod string, req, rsp interface{}, next Invoker) error {
+		start := time.Now()
+		err := next(ctx, serviceMethod, req, rsp)
+		cost := time.Since(start)
+		if err != nil {
+			logger.ErrorCtx(ctx, "call", "callee", serviceMethod, "req", req, "cost", cost.String(), "code", flerrors.CodeOf(err).String(), "err", err)
+			return err
+		}
+		logger.DebugCtx(ctx, "call", "callee", serviceMethod, "req", req, "rsp", rsp, "cost", cost.String())
+		return nil
+	}
+}
+
+// Metrics 每次调用结束后回调observe, 用于对接监控
+func Metrics(observe func(serviceMethod string, cost time.Duration, err error)) Interceptor {
+	return func(ctx context.Context, serviceMethod string, req, rsp interface{}, next Invoker) error {
+		start := time.Now()
+		err := next(ctx, serviceMethod, req, rsp)
+		observe(serviceMethod, time.Since(start), err)
+		return err
+	}
+}
+
+// Auth 在请求元数据中注入鉴权token, 服务端通过rpcx AuthFunc校验
+func Auth(token string) Interceptor {
+	return Header(map[string]string{share.AuthKey: token})
+}
+
+// Header 在请求元数据中注入固定的键值
+func Header(kv map[string]string) Interceptor {
+	return func(ctx context.Context, serviceMethod string, req, rsp interface{}, next Invoker) error {
+		return next(WithMetadata(ctx, kv), serviceMethod, req, rsp)
+	}
+}
+
+// Retry 失败后最多重试retries次, 每次间隔backoff并翻倍.
+// 只重试flerrors.IsRetryable的错误, ctx已取消/超时或熔断打开时不重试.
+// 链中有Retry时不再使用rpcx自身的重试(CallDesc.Retries), 避免两层重试相乘
+func Retry(retries int, backoff time.Duration) Interceptor {
+	return func(ctx context.Context, serviceMethod string, req, rsp interface{}, next Invoker) error {
+		err := next(ctx, serviceMethod, req, rsp)
+		// 每次调用从backoff重新开始, 不修改闭包共享的参数
+		delay := backoff
+		for i := 0; i < retries && shouldRetry(ctx, err); i++ {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+			delay *= 2
+			logger.WarnCtx(ctx, "retry", "callee", serviceMethod, "times", i+1, "err", err)
+			err = next(ctx, serviceMethod, req, rsp)
+		}
+		return err
+	}
+}
+
+// retryPC Retry返回的闭包共用同一段代码, 按代码地址识别链中的Retry
+var retryPC = reflect.ValueOf(Retry(0, 0)).Pointer()
+
+// hasRetry 拦截器链中是否有Retry
+func hasRetry(interceptors []Interceptor) bool {
+	for _, interceptor := range interceptors {
+		if reflect.ValueOf(interceptor).Pointer() == retryPC {
+			return true
+		}
+	}
+	return false
+}
+
+func shouldRetry(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return flerrors.IsRetryable(err)
+}
+
+// breakerFailure 按错误码判断, 不看ctx状态: CallDesc.Timeout到期时ctx已超时, 仍要计为失败
+func breakerFailure(err error) bool {
+	if errors.Is(err, context.Canceled) || flerrors.CodeOf(err) == flerrors.Canceled {
+		return false
+	}
+	return flerrors.IsRetryable(err)
+}
+
+// CircuitBreaker 按serviceMethod熔断: 连续失败failures次后打开, cooldown后放行一次探测,
+// 探测成功则关闭, 失败则继续打开. 下游不可用、超时与限流计为失败, 业务错误与调用方取消不计
+func CircuitBreaker(failures int, cooldown time.Duration) Interceptor {
+	var (
+		mu       sync.Mutex
+		breakers = make(map[string]*breaker)
+	)
+	return func(ctx context.Context, serviceMethod string, req, rsp interface{}, next Invoker) error {
+		mu.Lock()
+		b, ok := breakers[serviceMethod]
+		if !ok {
+			b = &breaker{}
+			breakers[serviceMethod] = b
+		}
+		mu.Unlock()
+
+		if !b.allow(cooldown) {
+			return ErrCircuitOpen
+		}
+		err := next(ctx, serviceMethod, req, rsp)
+		switch {
+		case err == nil:
+			b.done(true, failures)
+		case breakerFailure(err):
+			b.done(false, failures)
+		default:
+			// 业务错误或调用方取消, 不影响熔断状态
+			b.skip()
+		}
+		return err
+	}
+}
+
+type breaker struct {
+	mu       sync.Mutex
+	failures int
+	openAt   time.Time
+	probing  bool
+}
+
+func (b *breaker) allow(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openAt.IsZero() {
+		return true
+	}
+	if b.probing || time.Since(b.openAt) < cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) skip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) done(success bool, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		b.openAt = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= threshold {
+		b.openAt = time.Now()
+	}
+}
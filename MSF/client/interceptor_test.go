@@ -0,0 +1,78 @@
+package flcli
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xiaolongdeng1990/forlife/MSF/registry"
+)
+
+// brokenServer 读到请求后立即关闭连接, 返回到达服务端的请求数
+func brokenServer(t *testing.T) (string, *int64) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var requests int64
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				// 读到请求才算一次调用
+				buf := make([]byte, 1)
+				conn.SetReadDeadline(time.Now().Add(time.Second))
+				if n, _ := conn.Read(buf); n > 0 {
+					atomic.AddInt64(&requests, 1)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), &requests
+}
+
+func TestRetryAttempts(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      *int
+		interceptors []Interceptor
+		want         int64
+	}{
+		{name: "rpcx retries", retries: Int(3), want: 4},
+		{name: "rpcx no retry", retries: Int(0), want: 1},
+		{name: "interceptor only", retries: Int(3), interceptors: []Interceptor{Retry(2, time.Millisecond)}, want: 3},
+		{name: "interceptor with failfast", retries: Int(0), interceptors: []Interceptor{Retry(1, time.Millisecond)}, want: 2},
+	}
+	for _, tt := range tests {
+		addr, requests := brokenServer(t)
+		reg := registry.NewMemory()
+		reg.Register(registry.Instance{BasePath: "demo", SvrName: "Math", Addr: "tcp@" + addr})
+		c, err := NewClientE(CallDesc{
+			ServiceName:    "demo.Math.Add",
+			Registry:       reg,
+			ConnectTimeout: time.Second,
+			Retries:        tt.retries,
+			Interceptors:   tt.interceptors,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var rsp struct{ C int }
+		if err := c.DoRequest(ctx, &struct{ A, B int }{1, 2}, &rsp); err == nil {
+			t.Errorf("%s: want error from broken server", tt.name)
+		}
+		cancel()
+		c.Close()
+		if got := atomic.LoadInt64(requests); got != tt.want {
+			t.Errorf("%s: %d calls reached the server, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package flcli
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultIdleTimeout 引用计数归零后保留的时间, 超时后关闭
+const defaultIdleTimeout = 5 * time.Minute
+
+type managedClient struct {
+	client    *FlClient
+	err       error         // 创建失败的原因
+	ready     chan struct{} // 创建完成后关闭, 同一ServiceName并发GetClient只创建一次
+	refs      int
+	idleSince time.Time
+	closed    bool // 调用CloseAll时仍被引用, 最后一次Release时关闭
+}
+
+type clientManager struct {
+	mu          sync.Mutex
+	clients     map[string]*managedClient
+	idleTimeout time.Duration
+	stopCh      chan struct{}
+}
+
+var manager = &clientManager{
+	clients:     make(map[string]*managedClient),
+	idleTimeout: defaultIdleTimeout,
+}
+
+// GetClient 返回按ServiceName共享的FlClient, 首次调用时创建, 用完调用Release.
+// 同一ServiceName只有首次传入的CallDesc生效; 创建失败时返回的客户端在DoRequest时报错
+func GetClient(callDesc CallDesc) *FlClient {
+	flC, err := GetClientE(callDesc)
+	if err != nil {
+		logger.Error("get client failed", "service", callDesc.ServiceName, "err", err)
+		return &FlClient{err: err}
+	}
+	return flC
+}
+
+// GetClientE 同GetClient, 创建失败时返回错误且不缓存.
+// 服务发现与建连在锁外进行, 创建一个服务的客户端不阻塞其他服务
+func GetClientE(callDesc CallDesc) (*FlClient, error) {
+	key := strings.TrimPrefix(callDesc.ServiceName, "/")
+
+	manager.mu.Lock()
+	mc, ok := manager.clients[key]
+	if !ok {
+		mc = &managedClient{ready: make(chan struct{})}
+		manager.clients[key] = mc
+	}
+	// 创建期间也占一个引用, 不会被janitor回收
+	mc.refs++
+	manager.mu.Unlock()
+
+	if !ok {
+		mc.client, mc.err = NewClientE(callDesc)
+		if mc.client != nil {
+			mc.client.managed = mc
+		}
+		close(mc.ready)
+	}
+	<-mc.ready
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	if mc.err != nil {
+		mc.refs--
+		if manager.clients[key] == mc {
+			delete(manager.clients, key)
+		}
+		return nil, mc.err
+	}
+	manager.startJanitorLocked()
+	return mc.client, nil
+}
+
+// SetIdleTimeout 设置共享客户端无人引用后的保留时间
+func SetIdleTimeout(d time.Duration) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.idleTimeout = d
+}
+
+// CloseAll 关闭所有共享客户端, 进程退出前调用; 仍被引用的在最后一次Release时关闭
+func CloseAll() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for key, mc := range manager.clients {
+		delete(manager.clients, key)
+		if mc.refs == 0 {
+			mc.client.close()
+			continue
+		}
+		mc.closed = true
+	}
+	if manager.stopCh != nil {
+		close(manager.stopCh)
+		manager.stopCh = nil
+	}
+}
+
+// Release 归还GetClient取得的客户端, 引用归零且空闲超过IdleTimeout后关闭
+func (f *FlClient) Release() {
+	mc := f.managed
+	if mc == nil {
+		return
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if mc.refs == 0 {
+		return
+	}
+	mc.refs--
+	if mc.refs > 0 {
+		return
+	}
+	if mc.closed {
+		mc.client.close()
+		return
+	}
+	mc.idleSince = time.Now()
+}
+
+func (m *clientManager) startJanitorLocked() {
+	if m.stopCh != nil {
+		return
+	}
+	m.stopCh = make(chan struct{})
+	go m.janitor(m.stopCh)
+}
+
+// janitor 定期关闭空闲的客户端
+func (m *clientManager) janitor(stopCh chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			m.evictIdle(time.Now())
+		}
+	}
+}
+
+func (m *clientManager) evictIdle(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, mc := range m.clients {
+		if mc.refs == 0 && now.Sub(mc.idleSince) >= m.idleTimeout {
+			mc.client.close()
+			delete(m.clients, key)
+		}
+	}
+}
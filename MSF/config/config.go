@@ -10,8 +10,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/BurntSushi/toml"
 )
 
 const (
@@ -47,9 +45,20 @@ var logLevelStrMap = map[uint8]string{
 // LogLevel 日志级别
 type LogLevel uint8
 
+// ParseLogLevel 解析TRACE/DEBUG/INFO/WARN/ERROR/FATAL, 不区分大小写, WARNING同WARN
+func ParseLogLevel(s string) (LogLevel, error) {
+	var l LogLevel
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
 // UnmarshalText 通过字符串解析日志级别
 func (l *LogLevel) UnmarshalText(text []byte) error {
-	level, ok := logLevelMap[strings.ToLower(string(text))]
+	name := strings.ToLower(strings.TrimSpace(string(text)))
+	if name == "warning" {
+		name = "warn"
+	}
+	level, ok := logLevelMap[name]
 	if !ok {
 		return fmt.Errorf("not support log level %v", string(text))
 	}
@@ -57,6 +66,14 @@ func (l *LogLevel) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// MarshalText 输出大写的级别名, 未设置时为空
+func (l LogLevel) MarshalText() ([]byte, error) {
+	if l == LogLevelNull {
+		return nil, nil
+	}
+	return []byte(strings.ToUpper(l.String())), nil
+}
+
 // String 日志级别字符串展示
 func (l LogLevel) String() string {
 	name, ok := logLevelStrMap[uint8(l)]
@@ -80,24 +97,31 @@ func (l LogLevel) Value() uint8 {
 // LogSize 日志文件大小 B K M G
 type LogSize int64
 
-// UnmarshalText 通过字符串解析日志大小
+// UnmarshalText 通过字符串解析日志大小, 支持 1024、512K、512M、1G、1GB 等写法, 不带单位为字节
 func (l *LogSize) UnmarshalText(text []byte) error {
-	if len(text) < 2 { //至少两个字节
+	s := strings.ToLower(strings.TrimSpace(string(text)))
+	if len(s) > 2 && strings.HasSuffix(s, "b") && strings.ContainsAny(s[len(s)-2:len(s)-1], "kmg") {
+		s = s[:len(s)-1] // KB/MB/GB
+	}
+	if len(s) == 0 {
 		return fmt.Errorf("not support log size %v", string(text))
 	}
-	c := strings.ToLower(string(text[len(text)-1:])) //最后一个字符
-	n, e := strconv.ParseInt(string(text[:len(text)-1]), 10, 64)
-	if e != nil {
-		return e
+	var unit int64 = 1
+	switch s[len(s)-1] {
+	case 'b':
+		s = s[:len(s)-1]
+	case 'k':
+		unit, s = 1024, s[:len(s)-1]
+	case 'm':
+		unit, s = 1024*1024, s[:len(s)-1]
+	case 'g':
+		unit, s = 1024*1024*1024, s[:len(s)-1]
 	}
-	if c == "k" {
-		n *= 1024
-	} else if c == "m" {
-		n *= 1024 * 1024
-	} else if c == "g" {
-		n *= 1024 * 1024 * 1024
+	n, e := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if e != nil || n < 0 {
+		return fmt.Errorf("not support log size %v", string(text))
 	}
-	*l = LogSize(n)
+	*l = LogSize(n * unit)
 	return nil
 }
 
@@ -153,16 +177,24 @@ func (d Duration) Value() time.Duration {
 // UnmarshalText 字符串解析时间
 func (d *Duration) UnmarshalText(text []byte) error {
 	var err error
-	dd, err := time.ParseDuration(string(text))
+	dd, err := parseDuration(string(text))
 	if err == nil {
 		*d = Duration(dd)
 	}
 	return err
 }
 
+// parseDuration 支持"1.5s"等写法; 不带单位的整数为纳秒, 与toml解码time.Duration一致
+func parseDuration(s string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	return time.ParseDuration(s)
+}
+
 // Parse parse config with default and config file ../conf/config.toml
 func Parse(c interface{}) error {
-	return ParseConfigWithoutDefaults(c)
+	return ParseConfigWithPath(c, ConfPath)
 }
 
 // ParseConfig same as Parse
@@ -170,17 +202,22 @@ func ParseConfig(c interface{}) error {
 	return Parse(c)
 }
 
-// ParseConfigWithPath 自己定义配置文件路径
+// ParseConfigWithPath 自己定义配置文件路径, 先按default tag填充默认值再解码.
+// 不读环境变量与命令行覆盖, 也不校验, 需要时用Load
 func ParseConfigWithPath(c interface{}, path string) error {
-	if _, err := toml.DecodeFile(path, c); err != nil {
+	if err := SetDefaults(c); err != nil {
 		return err
 	}
-	return nil
+	inFile, err := decodeFile(c, path, FormatOf(path))
+	if err != nil {
+		return err
+	}
+	return setElemDefaults(c, inFile)
 }
 
 // ParseConfigWithoutDefaults no default value
 func ParseConfigWithoutDefaults(c interface{}) error {
-	if _, err := toml.DecodeFile(ConfPath, c); err != nil {
+	if _, err := decodeFile(c, ConfPath, FormatOf(ConfPath)); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type dfServer struct {
+	Address string        `default:":8080"`
+	Timeout Duration      `default:"1.5s"`
+	Idle    time.Duration `default:"30s"`
+}
+
+type dfClient struct {
+	Name    string
+	Retries int `default:"3"`
+}
+
+type dfCfg struct {
+	Name   string   `default:"demo"`
+	Level  LogLevel `default:"info"`
+	Size   LogSize  `default:"512M"`
+	Tags   []string `default:"a, b"`
+	Ports  []int    `default:"80,443"`
+	Enable *bool    `default:"true"`
+	Server dfServer
+	Client []dfClient
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSetDefaults(t *testing.T) {
+	on, off := true, false
+	all := dfCfg{
+		Name:   "demo",
+		Level:  LogLevelInfo,
+		Size:   512 * 1024 * 1024,
+		Tags:   []string{"a", "b"},
+		Ports:  []int{80, 443},
+		Enable: &on,
+		Server: dfServer{Address: ":8080", Timeout: Duration(1500 * time.Millisecond), Idle: 30 * time.Second},
+	}
+	tests := []struct {
+		name string
+		in   dfCfg
+		want func(c *dfCfg)
+	}{
+		{name: "empty", want: func(c *dfCfg) {}},
+		{name: "keep set", in: dfCfg{Name: "x", Level: LogLevelError, Size: 1024, Tags: []string{"c"}},
+			want: func(c *dfCfg) { c.Name, c.Level, c.Size, c.Tags = "x", LogLevelError, 1024, []string{"c"} }},
+		{name: "keep nested", in: dfCfg{Server: dfServer{Address: ":9090", Idle: time.Second}},
+			want: func(c *dfCfg) { c.Server.Address, c.Server.Idle = ":9090", time.Second }},
+		// 非nil指针不再填充, 即使指向false
+		{name: "keep false pointer", in: dfCfg{Enable: &off}, want: func(c *dfCfg) { c.Enable = &off }},
+		// 已有的切片元素补零值字段
+		{name: "fill elems", in: dfCfg{Client: []dfClient{{Name: "a"}, {Name: "b", Retries: 1}}},
+			want: func(c *dfCfg) { c.Client = []dfClient{{Name: "a", Retries: 3}, {Name: "b", Retries: 1}} }},
+	}
+	for _, tt := range tests {
+		got := tt.in
+		if err := SetDefaults(&got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		want := all
+		tt.want(&want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestSetDefaultsError(t *testing.T) {
+	tests := []struct {
+		name string
+		c    interface{}
+	}{
+		{name: "not pointer", c: dfCfg{}},
+		{name: "nil", c: (*dfCfg)(nil)},
+		{name: "bad level", c: &struct {
+			Level LogLevel `default:"loud"`
+		}{}},
+		{name: "bad size", c: &struct {
+			Size LogSize `default:"1T"`
+		}{}},
+		{name: "bad duration", c: &struct {
+			Timeout Duration `default:"1 minute"`
+		}{}},
+		{name: "bad slice", c: &struct {
+			Ports []int `default:"80,x"`
+		}{}},
+		{name: "not support", c: &struct {
+			M map[string]string `default:"a"`
+		}{}},
+	}
+	for _, tt := range tests {
+		if err := SetDefaults(tt.c); err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+	}
+}
+
+func TestParseConfigWithPathDefaults(t *testing.T) {
+	path := writeFile(t, "df.toml", `Name = "file"
+Size = "1G"
+
+[Server]
+Idle = "5s"
+
+[[Client]]
+Name = "a"
+
+[[Client]]
+Name = "b"
+Retries = 0
+`)
+	var c dfCfg
+	if err := ParseConfigWithPath(&c, path); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "file over default", got: c.Name, want: "file"},
+		{name: "file size", got: c.Size, want: LogSize(1024 * 1024 * 1024)},
+		{name: "default level", got: c.Level, want: LogLevel(LogLevelInfo)},
+		{name: "nested file", got: c.Server.Idle, want: 5 * time.Second},
+		{name: "nested default", got: c.Server.Timeout, want: Duration(1500 * time.Millisecond)},
+		{name: "slice default", got: c.Ports, want: []int{80, 443}},
+		{name: "elem default", got: c.Client[0].Retries, want: 3},
+		// 文件中写了0的元素字段保持0
+		{name: "elem zero in file", got: c.Client[1].Retries, want: 0},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	typeOfDuration        = reflect.TypeOf(time.Duration(0))
+	typeOfTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// SetDefaults 按字段的 default:"..." 填充c中仍为零值的字段, c须为结构体指针
+// 支持string、bool、整数、浮点、time.Duration、实现了encoding.TextUnmarshaler的类型(LogLevel、LogSize、Duration等)
+// 切片以逗号分隔, 未打tag的结构体字段递归处理
+func SetDefaults(c interface{}) error {
+	v := reflect.ValueOf(c)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: SetDefaults need a struct pointer, got %T", c)
+	}
+	d := defaulter{tags: true}
+	return d.setStruct(v.Elem(), "")
+}
+
+// setElemDefaults 解码后调用, 只处理解码新建的切片与map元素, 跳过文件中写了的字段
+// defined的key为小写的配置路径, 切片元素带下标, 如 client[1].retries
+func setElemDefaults(c interface{}, defined map[string]bool) error {
+	v := reflect.ValueOf(c)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	d := defaulter{defined: defined}
+	return d.setStruct(v.Elem(), "")
+}
+
+// defaulter tags为false时只递归, 不填充本层字段
+type defaulter struct {
+	tags    bool
+	defined map[string]bool
+}
+
+func (d defaulter) setStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := prefix + field.Name
+		tag, ok := field.Tag.Lookup("default")
+		if d.tags && ok && len(tag) > 0 && fv.IsZero() && !d.defined[strings.ToLower(path)] {
+			if err := setValue(fv, tag); err != nil {
+				return fmt.Errorf("config: default of %s: %w", path, err)
+			}
+			continue
+		}
+		if err := d.setNested(fv, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setNested 结构体递归; 切片与map中的结构体元素由解码产生, 只补零值字段
+func (d defaulter) setNested(v reflect.Value, path string) error {
+	if isText(v.Type()) {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return d.setStruct(v, path+".")
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return d.setNested(v.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		elem := defaulter{tags: true, defined: d.defined}
+		for i := 0; i < v.Len(); i++ {
+			if err := elem.setNested(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		d := defaulter{tags: true, defined: d.defined}
+		iter := v.MapRange()
+		for iter.Next() {
+			// map元素不可寻址, 复制后写回
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			if err := d.setStruct(elem, fmt.Sprintf("%s.%v.", path, iter.Key())); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+	return nil
+}
+
+// isText 实现了TextUnmarshaler的类型整体解析, 不再递归
+func isText(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(typeOfTextUnmarshaler)
+}
+
+// setValue 将tag中的字符串按字段类型解析
+func setValue(v reflect.Value, s string) error {
+	if isText(v.Type()) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == typeOfDuration {
+		d, err := parseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setValue(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+	default:
+		return fmt.Errorf("not support default for %s", v.Type())
+	}
+	return nil
+}
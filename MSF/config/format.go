@@ -0,0 +1,242 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件格式
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// DefaultFormat 扩展名无法识别时使用的格式
+var DefaultFormat = FormatTOML
+
+// FormatOf 按扩展名判断格式: .toml/.yaml/.yml/.json, 其余为DefaultFormat
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	}
+	return DefaultFormat
+}
+
+// decodeFile 按format解码path到c, 返回文件中写了的key, 格式同definedKeys.
+// YAML与JSON先解析为通用的map再按字段名(不区分大小写)赋值, 与toml的写法一致, 标量统一经setValue解析
+func decodeFile(c interface{}, path, format string) (map[string]bool, error) {
+	var tree interface{}
+	switch strings.ToLower(format) {
+	case FormatTOML:
+		md, err := toml.DecodeFile(path, c)
+		if err != nil {
+			return nil, err
+		}
+		return definedKeys(md), nil
+	case FormatYAML, "yml":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", path, err)
+		}
+	case FormatJSON:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("config: not support format %q", format)
+	}
+
+	defined := map[string]bool{}
+	if tree == nil {
+		// 空文件
+		return defined, nil
+	}
+	v := reflect.ValueOf(c)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, fmt.Errorf("config: decode need a pointer, got %T", c)
+	}
+	d := treeDecoder{defined: defined}
+	if err := d.decode(v.Elem(), tree, ""); err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return defined, nil
+}
+
+// treeDecoder 将YAML/JSON解析出的map、切片与标量赋值到结构体
+type treeDecoder struct {
+	defined map[string]bool
+}
+
+func (d treeDecoder) decode(v reflect.Value, data interface{}, path string) error {
+	if data == nil {
+		// YAML中的 key: 未填值, 保留原值
+		return nil
+	}
+	if isText(v.Type()) || v.Type() == typeOfDuration {
+		return d.scalar(v, data, path)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem(), data, path)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(data))
+			return nil
+		}
+	case reflect.Struct:
+		m, err := toMap(data, path)
+		if err != nil {
+			return err
+		}
+		return d.decodeStruct(v, m, path)
+	case reflect.Map:
+		m, err := toMap(data, path)
+		if err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(m)))
+		}
+		for k, item := range m {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := setValue(key, k); err != nil {
+				return fmt.Errorf("%s: key %q: %w", path, k, err)
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decode(elem, item, join(path, k)); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+		d.defined[strings.ToLower(path)] = true
+		return nil
+	case reflect.Slice:
+		items, ok := data.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want array, got %T", path, data)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := d.decode(slice.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		d.defined[strings.ToLower(path)] = true
+		return nil
+	}
+	return d.scalar(v, data, path)
+}
+
+// decodeStruct 字段名不区分大小写, 没有对应字段的key忽略, 与toml一致
+func (d treeDecoder) decodeStruct(v reflect.Value, m map[string]interface{}, prefix string) error {
+	t := v.Type()
+	for k, item := range m {
+		idx := -1
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if t.Field(i).Name == k {
+				idx = i
+				break
+			}
+			if idx < 0 && strings.EqualFold(t.Field(i).Name, k) {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			continue
+		}
+		if err := d.decode(v.Field(idx), item, join(prefix, t.Field(idx).Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d treeDecoder) scalar(v reflect.Value, data interface{}, path string) error {
+	s, ok := scalarString(data)
+	if !ok {
+		return fmt.Errorf("%s: want %s, got %T", path, v.Type(), data)
+	}
+	if v.Kind() == reflect.Bool && !isText(v.Type()) {
+		if _, ok := data.(bool); !ok {
+			return fmt.Errorf("%s: want bool, got %q", path, s)
+		}
+	}
+	if err := setValue(v, s); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	d.defined[strings.ToLower(path)] = true
+	return nil
+}
+
+// scalarString 标量转为与toml写法一致的字符串, 交给setValue按字段类型解析
+func scalarString(data interface{}) (string, bool) {
+	switch x := data.(type) {
+	case string:
+		return x, true
+	case bool:
+		return strconv.FormatBool(x), true
+	case json.Number:
+		return x.String(), true
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), true
+	case int, int64, uint64:
+		return fmt.Sprint(x), true
+	case time.Time:
+		return x.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+// toMap YAML中key不全是字符串的map解析为map[interface{}]interface{}
+func toMap(data interface{}, path string) (map[string]interface{}, error) {
+	switch m := data.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, item := range m {
+			out[fmt.Sprint(k)] = item
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%s: want table, got %T", path, data)
+}
+
+func join(prefix, name string) string {
+	if len(prefix) == 0 {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fmServer struct {
+	Address string `validate:"hostport"`
+	Timeout Duration
+	Idle    time.Duration
+	Debug   *bool
+	Tags    []string
+}
+
+type fmClient struct {
+	ServiceName string
+	Retries     int `default:"3"`
+	Weight      float64
+}
+
+type fmCfg struct {
+	Name    string `default:"demo"`
+	Level   LogLevel
+	MaxSize LogSize
+	Server  fmServer
+	Client  []fmClient
+	Labels  map[string]string
+}
+
+// 同一份配置的三种写法
+var fmFixtures = []struct {
+	name, data string
+}{
+	{name: "fm.toml", data: `Level = "debug"
+MaxSize = "512M"
+
+[Server]
+Address = ":8080"
+Timeout = "1.5s"
+Idle = "30s"
+Debug = false
+Tags = ["a", "b"]
+
+[[Client]]
+ServiceName = "demo.Math"
+Weight = 0.5
+
+[[Client]]
+ServiceName = "demo.Order"
+Retries = 0
+
+[Labels]
+zone = "sz"
+`},
+	{name: "fm.yaml", data: `level: debug
+maxsize: 512M
+server:
+  address: ":8080"
+  timeout: 1.5s
+  idle: 30s
+  debug: false
+  tags: [a, b]
+client:
+  - servicename: demo.Math
+    weight: 0.5
+  - servicename: demo.Order
+    retries: 0
+labels:
+  zone: sz
+`},
+	{name: "fm.json", data: `{
+  "Level": "debug",
+  "MaxSize": "512M",
+  "Server": {"Address": ":8080", "Timeout": "1.5s", "Idle": "30s", "Debug": false, "Tags": ["a", "b"]},
+  "Client": [
+    {"ServiceName": "demo.Math", "Weight": 0.5},
+    {"ServiceName": "demo.Order", "Retries": 0}
+  ],
+  "Labels": {"zone": "sz"}
+}`},
+}
+
+func TestLoadFormats(t *testing.T) {
+	off := false
+	want := fmCfg{
+		Name:    "demo",
+		Level:   LogLevelDebug,
+		MaxSize: 512 * 1024 * 1024,
+		Server: fmServer{
+			Address: ":8080",
+			Timeout: Duration(1500 * time.Millisecond),
+			Idle:    30 * time.Second,
+			Debug:   &off,
+			Tags:    []string{"a", "b"},
+		},
+		Client: []fmClient{
+			{ServiceName: "demo.Math", Retries: 3, Weight: 0.5},
+			{ServiceName: "demo.Order", Retries: 0},
+		},
+		Labels: map[string]string{"zone": "sz"},
+	}
+	var first Sources
+	for _, tt := range fmFixtures {
+		path := writeFile(t, tt.name, tt.data)
+		var c fmCfg
+		sources, err := Load(&c, path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(c, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, c, want)
+		}
+		// 来源与格式无关
+		if first == nil {
+			first = sources
+		} else if !reflect.DeepEqual(sources, first) {
+			t.Errorf("%s: sources = %v, want %v", tt.name, sources, first)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "a.toml", want: FormatTOML},
+		{path: "a.YAML", want: FormatYAML},
+		{path: "a.yml", want: FormatYAML},
+		{path: "conf/a.json", want: FormatJSON},
+		{path: "a.conf", want: DefaultFormat},
+		{path: "a", want: DefaultFormat},
+	}
+	for _, tt := range tests {
+		if got := FormatOf(tt.path); got != tt.want {
+			t.Errorf("FormatOf(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFormatError(t *testing.T) {
+	tests := []struct {
+		name, format, data string
+	}{
+		{name: "bad.yaml", data: "server: [1"},
+		{name: "bad.json", data: `{"Server": `},
+		{name: "type.json", data: `{"Server": "x"}`},
+		{name: "bool.yaml", data: "server:\n  debug: yes please"},
+		{name: "level.json", data: `{"Level": "loud"}`},
+		{name: "a.conf", format: "ini", data: ""},
+	}
+	for _, tt := range tests {
+		path := writeFile(t, tt.name, tt.data)
+		var c fmCfg
+		if _, err := LoadFormat(&c, path, tt.format); err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// 配置值的来源, 后面的覆盖前面的
+const (
+	SourceDefault = "default" // default tag
+	SourceFile    = "file"    // 配置文件
+	SourceEnv     = "env"     // 环境变量
+	SourceFlag    = "flag"    // 命令行覆盖
+)
+
+// EnvPrefix 环境变量前缀, Server.Address对应FORLIFE_SERVER_ADDRESS; 字段的env tag优先, 如 env:"CONSUL_ADDR"
+var EnvPrefix = "FORLIFE"
+
+// Sources 每个字段(配置路径, 如Server.Address)的值来自哪一层, 环境变量带上变量名
+type Sources map[string]string
+
+var (
+	overrideMu sync.RWMutex
+	overrides  = map[string]string{}
+)
+
+// SetOverride 设置命令行覆盖, key为配置路径(不区分大小写), 对之后加载的所有配置生效
+func SetOverride(key, value string) {
+	overrideMu.Lock()
+	overrides[strings.ToLower(key)] = value
+	overrideMu.Unlock()
+}
+
+type overrideFlag struct{}
+
+func (overrideFlag) String() string { return "" }
+
+func (overrideFlag) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("want key=value, got %q", s)
+	}
+	SetOverride(strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]))
+	return nil
+}
+
+// RegisterFlags 注册可重复的 -set Server.Address=:8080 参数, 在flag.Parse之前调用
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.Var(overrideFlag{}, "set", "override config value, e.g. -set Server.Address=:8080 (repeatable)")
+}
+
+// Load 分层加载: default tag -> 配置文件 -> 环境变量 -> 命令行覆盖, 最后按validate tag校验, 返回每个字段的来源.
+// 文件格式按扩展名判断, 见FormatOf
+func Load(c interface{}, path string) (Sources, error) {
+	return LoadFormat(c, path, "")
+}
+
+// LoadFormat 同Load, 指定文件格式FormatTOML/FormatYAML/FormatJSON, 为空时按扩展名判断
+func LoadFormat(c interface{}, path, format string) (Sources, error) {
+	if len(format) == 0 {
+		format = FormatOf(path)
+	}
+	if err := SetDefaults(c); err != nil {
+		return nil, err
+	}
+	sources := Sources{}
+	v := reflect.ValueOf(c).Elem()
+	walkLeaves(v, "", false, func(p string, _ reflect.StructField, fv reflect.Value) error {
+		if !fv.IsZero() {
+			sources[p] = SourceDefault
+		}
+		return nil
+	})
+
+	inFile, err := decodeFile(c, path, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := setElemDefaults(c, inFile); err != nil {
+		return nil, err
+	}
+
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+	err = walkLeaves(v, "", true, func(p string, field reflect.StructField, fv reflect.Value) error {
+		elem := strings.ContainsRune(p, '[')
+		if inFile[strings.ToLower(p)] {
+			sources[p] = SourceFile
+		} else if elem && !fv.IsZero() {
+			sources[p] = SourceDefault
+		}
+		if elem {
+			// 切片中的元素只能来自文件
+			return nil
+		}
+		name := envName(p, field)
+		if s, ok := os.LookupEnv(name); ok {
+			if err := setValue(fv, s); err != nil {
+				return fmt.Errorf("config: env %s: %w", name, err)
+			}
+			sources[p] = SourceEnv + " " + name
+		}
+		if s, ok := overrides[strings.ToLower(p)]; ok {
+			if err := setValue(fv, s); err != nil {
+				return fmt.Errorf("config: flag %s: %w", p, err)
+			}
+			sources[p] = SourceFlag
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(c); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// Print 按路径排序输出生效的配置及来源, 如 Server.Address = ":8080" # env FORLIFE_SERVER_ADDRESS
+func Print(w io.Writer, c interface{}, sources Sources) {
+	type line struct{ path, text string }
+	var lines []line
+	walkLeaves(reflect.ValueOf(c).Elem(), "", true, func(p string, _ reflect.StructField, fv reflect.Value) error {
+		text := fmt.Sprintf("%s = %s", p, formatValue(fv))
+		if src, ok := sources[p]; ok {
+			text += " # " + src
+		}
+		lines = append(lines, line{p, text})
+		return nil
+	})
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].path < lines[j].path })
+	for _, l := range lines {
+		fmt.Fprintln(w, l.text)
+	}
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "<nil>"
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return fmt.Sprintf("%q", s.String())
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// envName env tag优先, 否则为 前缀_路径大写, 点号换成下划线
+func envName(path string, field reflect.StructField) string {
+	if name, ok := field.Tag.Lookup("env"); ok && len(name) > 0 {
+		return name
+	}
+	name := strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+	if len(EnvPrefix) > 0 {
+		name = EnvPrefix + "_" + name
+	}
+	return name
+}
+
+// definedKeys toml文件中写了的key, 小写; [[Client]]按出现顺序编号, 如 client[1].retries
+func definedKeys(md toml.MetaData) map[string]bool {
+	keys := md.Keys()
+	defined := make(map[string]bool, len(keys))
+	counts := map[string]int{}
+	var arrays []string // 出现过的数组表路径, 长的在前
+	for _, key := range keys {
+		k := strings.ToLower(key.String())
+		if md.Type(key...) == "ArrayHash" {
+			if _, ok := counts[k]; !ok {
+				arrays = append(arrays, k)
+				sort.Slice(arrays, func(i, j int) bool { return len(arrays[i]) > len(arrays[j]) })
+			}
+			counts[k]++
+			continue
+		}
+		for _, a := range arrays {
+			if strings.HasPrefix(k, a+".") {
+				k = fmt.Sprintf("%s[%d]%s", a, counts[a]-1, k[len(a):])
+				break
+			}
+		}
+		defined[k] = true
+	}
+	return defined
+}
+
+// walkLeaves 遍历结构体中的叶子字段, 结构体递归; elems为true时也遍历切片中的结构体元素
+func walkLeaves(v reflect.Value, prefix string, elems bool, fn func(path string, field reflect.StructField, v reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := prefix + field.Name
+		switch {
+		case isText(fv.Type()):
+		case fv.Kind() == reflect.Struct:
+			if err := walkLeaves(fv, path+".", elems, fn); err != nil {
+				return err
+			}
+			continue
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; elems && j < fv.Len(); j++ {
+				if err := walkLeaves(fv.Index(j), fmt.Sprintf("%s[%d].", path, j), elems, fn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := fn(path, field, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
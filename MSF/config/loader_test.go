@@ -0,0 +1,160 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ldServer struct {
+	Address string   `default:":8080"`
+	Timeout Duration `default:"1s"`
+	Name    string
+	Level   LogLevel `default:"info" env:"LD_LEVEL"`
+	Port    int
+}
+
+type ldClient struct {
+	Name    string
+	Retries int `default:"3"`
+}
+
+type ldCfg struct {
+	Server ldServer
+	Client []ldClient
+}
+
+// resetOverrides 测试结束时清掉命令行覆盖, 避免影响其他测试
+func resetOverrides(t *testing.T) {
+	t.Cleanup(func() {
+		overrideMu.Lock()
+		overrides = map[string]string{}
+		overrideMu.Unlock()
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	resetOverrides(t)
+	path := writeFile(t, "ld.toml", `[Server]
+Timeout = "2s"
+Name = "file"
+Level = "warn"
+
+[[Client]]
+Name = "a"
+
+[[Client]]
+Name = "b"
+Retries = 1
+`)
+	t.Setenv("FORLIFE_SERVER_NAME", "env")
+	t.Setenv("LD_LEVEL", "error")
+	// 有env tag时不读默认变量名
+	t.Setenv("FORLIFE_SERVER_LEVEL", "trace")
+	SetOverride("server.level", "debug")
+
+	var c ldCfg
+	sources, err := Load(&c, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path    string
+		got     interface{}
+		want    interface{}
+		wantSrc string
+	}{
+		{path: "Server.Address", got: c.Server.Address, want: ":8080", wantSrc: SourceDefault},
+		{path: "Server.Timeout", got: c.Server.Timeout, want: Duration(2 * time.Second), wantSrc: SourceFile},
+		{path: "Server.Name", got: c.Server.Name, want: "env", wantSrc: SourceEnv + " FORLIFE_SERVER_NAME"},
+		{path: "Server.Level", got: c.Server.Level, want: LogLevel(LogLevelDebug), wantSrc: SourceFlag},
+		{path: "Server.Port", got: c.Server.Port, want: 0},
+		{path: "Client[0].Name", got: c.Client[0].Name, want: "a", wantSrc: SourceFile},
+		{path: "Client[0].Retries", got: c.Client[0].Retries, want: 3, wantSrc: SourceDefault},
+		{path: "Client[1].Retries", got: c.Client[1].Retries, want: 1, wantSrc: SourceFile},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, tt.got, tt.want)
+		}
+		if src := sources[tt.path]; src != tt.wantSrc {
+			t.Errorf("%s source = %q, want %q", tt.path, src, tt.wantSrc)
+		}
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, &c, sources)
+	for _, line := range []string{
+		`Server.Address = ":8080" # default`,
+		`Server.Level = "debug" # flag`,
+		`Server.Name = "env" # env FORLIFE_SERVER_NAME`,
+		`Server.Port = 0`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Print missing %q in\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestLoadOverrideError(t *testing.T) {
+	resetOverrides(t)
+	path := writeFile(t, "ld.toml", "")
+	tests := []struct {
+		name string
+		env  string
+		flag string
+	}{
+		{name: "env", env: "5x"},
+		{name: "flag", flag: "loud"},
+	}
+	for _, tt := range tests {
+		if len(tt.env) > 0 {
+			t.Setenv("FORLIFE_SERVER_PORT", tt.env)
+		}
+		if len(tt.flag) > 0 {
+			SetOverride("Server.Level", tt.flag)
+		}
+		var c ldCfg
+		if _, err := Load(&c, path); err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+		os.Unsetenv("FORLIFE_SERVER_PORT")
+		overrideMu.Lock()
+		overrides = map[string]string{}
+		overrideMu.Unlock()
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	resetOverrides(t)
+	tests := []struct {
+		args    []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{args: []string{"-set", "Server.Address=:9090"}, want: map[string]string{"server.address": ":9090"}},
+		{args: []string{"-set", "Server.Name = a=b", "-set", "Server.Port=1"}, want: map[string]string{"server.name": "a=b", "server.port": "1"}},
+		{args: []string{"-set", "Server.Address"}, wantErr: true},
+		{args: []string{"-set", "=x"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		overrideMu.Lock()
+		overrides = map[string]string{}
+		overrideMu.Unlock()
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(&bytes.Buffer{})
+		RegisterFlags(fs)
+		err := fs.Parse(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: err = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(overrides, tt.want) {
+			t.Errorf("%v: overrides = %v, want %v", tt.args, overrides, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError 一个字段未通过校验, Path为配置路径
+type FieldError struct {
+	Path string
+	Rule string
+	Msg  string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// ValidationError 所有未通过校验的字段
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return "config invalid: " + strings.Join(msgs, "; ")
+}
+
+// Validate 按字段的 validate:"..." 校验c, 规则以逗号分隔:
+// required 不能为零值; hostport 形如host:port, host可为空; oneof=a b c 不区分大小写;
+// min=N/max=N 数字比较大小, 字符串与切片比较长度, LogSize/Duration等按各自格式写, 如min=1M
+// 零值且没有required时跳过其他规则; 基本类型的切片逐个元素校验. 返回的错误为ValidationError
+func Validate(c interface{}) error {
+	v := reflect.ValueOf(c)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: Validate need a struct pointer, got %T", c)
+	}
+	var errs ValidationError
+	walkLeaves(v.Elem(), "", true, func(p string, field reflect.StructField, fv reflect.Value) error {
+		if tag, ok := field.Tag.Lookup("validate"); ok && len(tag) > 0 {
+			errs = append(errs, validateField(p, fv, tag)...)
+		}
+		return nil
+	})
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateField(path string, v reflect.Value, tag string) []FieldError {
+	var errs []FieldError
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if isEmpty(v) {
+				return []FieldError{{Path: path, Rule: name, Msg: "required"}}
+			}
+			continue
+		}
+		if isEmpty(v) {
+			continue
+		}
+		if v.Kind() == reflect.Slice && !isText(v.Type()) && name != "min" && name != "max" {
+			for i := 0; i < v.Len(); i++ {
+				if msg := checkRule(v.Index(i), name, arg); len(msg) > 0 {
+					errs = append(errs, FieldError{Path: fmt.Sprintf("%s[%d]", path, i), Rule: name, Msg: msg})
+				}
+			}
+			continue
+		}
+		if msg := checkRule(v, name, arg); len(msg) > 0 {
+			errs = append(errs, FieldError{Path: path, Rule: name, Msg: msg})
+		}
+	}
+	return errs
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// checkRule 通过返回空字符串
+func checkRule(v reflect.Value, name, arg string) string {
+	switch name {
+	case "hostport":
+		s := stringOf(v)
+		_, port, err := net.SplitHostPort(s)
+		if err != nil {
+			return fmt.Sprintf("invalid host:port %q", s)
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Sprintf("invalid port in %q", s)
+		}
+	case "oneof":
+		s := stringOf(v)
+		options := strings.Fields(arg)
+		for _, o := range options {
+			if strings.EqualFold(o, s) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s], got %q", strings.Join(options, " "), s)
+	case "min", "max":
+		cmp, err := compare(v, arg)
+		if err != nil {
+			return fmt.Sprintf("bad rule %s=%s: %v", name, arg, err)
+		}
+		if name == "min" && cmp < 0 {
+			return fmt.Sprintf("must be >= %s, got %s", arg, stringOf(v))
+		}
+		if name == "max" && cmp > 0 {
+			return fmt.Sprintf("must be <= %s, got %s", arg, stringOf(v))
+		}
+	default:
+		return fmt.Sprintf("unknown rule %q", name)
+	}
+	return ""
+}
+
+// compare v与arg比较, 返回-1/0/1; arg按v的类型解析
+func compare(v reflect.Value, arg string) (int, error) {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return 0, err
+		}
+		return sign(float64(v.Len()) - float64(n)), nil
+	}
+	limit := reflect.New(v.Type()).Elem()
+	if err := setValue(limit, arg); err != nil {
+		return 0, err
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sign(float64(v.Int()) - float64(limit.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return sign(float64(v.Uint()) - float64(limit.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return sign(v.Float() - limit.Float()), nil
+	}
+	return 0, fmt.Errorf("not comparable %s", v.Type())
+}
+
+func sign(f float64) int {
+	if f < 0 {
+		return -1
+	} else if f > 0 {
+		return 1
+	}
+	return 0
+}
+
+// stringOf 实现了TextMarshaler的类型用其文本, 与配置文件中的写法一致
+func stringOf(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type vdServer struct {
+	Address string   `validate:"required,hostport"`
+	Network string   `validate:"oneof=tcp udp"`
+	MaxSize LogSize  `validate:"min=1M,max=1G"`
+	Timeout Duration `validate:"max=10s"`
+	Workers int      `validate:"min=1"`
+}
+
+type vdClient struct {
+	ServiceName string `validate:"required"`
+	Retries     int    `validate:"min=0,max=10"`
+}
+
+type vdCfg struct {
+	Server vdServer
+	Client []vdClient
+	Level  LogLevel `validate:"required"`
+	Addrs  []string `validate:"min=1,hostport"`
+}
+
+func TestValidateRules(t *testing.T) {
+	valid := vdServer{Address: ":8080", Network: "tcp", MaxSize: 1024 * 1024, Workers: 1}
+	tests := []struct {
+		name string
+		set  func(s *vdServer)
+		want []string // Path/Rule
+	}{
+		{name: "valid", set: func(s *vdServer) {}},
+		{name: "required", set: func(s *vdServer) { s.Address = "" }, want: []string{"Server.Address/required"}},
+		{name: "hostport host only", set: func(s *vdServer) { s.Address = "localhost" }, want: []string{"Server.Address/hostport"}},
+		{name: "hostport bad port", set: func(s *vdServer) { s.Address = "a:99999" }, want: []string{"Server.Address/hostport"}},
+		{name: "oneof ignore case", set: func(s *vdServer) { s.Network = "UDP" }},
+		{name: "oneof", set: func(s *vdServer) { s.Network = "unix" }, want: []string{"Server.Network/oneof"}},
+		// 零值且没有required时跳过
+		{name: "zero skip", set: func(s *vdServer) { s.Network, s.MaxSize, s.Workers = "", 0, 0 }},
+		{name: "min size", set: func(s *vdServer) { s.MaxSize = 1024 }, want: []string{"Server.MaxSize/min"}},
+		{name: "max size", set: func(s *vdServer) { s.MaxSize = 2 * 1024 * 1024 * 1024 }, want: []string{"Server.MaxSize/max"}},
+		{name: "max duration", set: func(s *vdServer) { s.Timeout = Duration(11e9) }, want: []string{"Server.Timeout/max"}},
+		{name: "min int", set: func(s *vdServer) { s.Workers = -1 }, want: []string{"Server.Workers/min"}},
+	}
+	for _, tt := range tests {
+		c := vdCfg{Server: valid, Level: LogLevelInfo}
+		tt.set(&c.Server)
+		if got := fieldErrors(t, Validate(&c)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: errors = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fieldErrors 将ValidationError转为 Path/Rule 列表
+func fieldErrors(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var ve ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("err %v is not ValidationError", err)
+	}
+	var got []string
+	for _, fe := range ve {
+		got = append(got, fe.Path+"/"+fe.Rule)
+	}
+	return got
+}
+
+func TestLoadValidateAggregate(t *testing.T) {
+	path := writeFile(t, "vd.toml", `Addrs = [":1", "bad", ":2"]
+
+[Server]
+Address = "localhost"
+Network = "unix"
+MaxSize = "10G"
+
+[[Client]]
+ServiceName = "demo.Math"
+Retries = 11
+
+[[Client]]
+Retries = 1
+`)
+	var c vdCfg
+	_, err := Load(&c, path)
+	want := []string{
+		"Server.Address/hostport",
+		"Server.Network/oneof",
+		"Server.MaxSize/max",
+		"Client[0].Retries/max",
+		"Client[1].ServiceName/required",
+		"Level/required",
+		"Addrs[1]/hostport",
+	}
+	if got := fieldErrors(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %v, want %v", got, want)
+	}
+}
+
+func TestValidateBadRule(t *testing.T) {
+	tests := []struct {
+		name string
+		c    interface{}
+	}{
+		{name: "unknown", c: &struct {
+			A string `validate:"email"`
+		}{A: "a"}},
+		{name: "bad arg", c: &struct {
+			A int `validate:"min=x"`
+		}{A: 1}},
+		{name: "not comparable", c: &struct {
+			A bool `validate:"min=1"`
+		}{A: true}},
+	}
+	for _, tt := range tests {
+		if got := fieldErrors(t, Validate(tt.c)); len(got) != 1 {
+			t.Errorf("%s: errors = %v, want 1", tt.name, got)
+		}
+	}
+	if err := Validate(vdCfg{}); err == nil || errors.As(err, new(ValidationError)) {
+		t.Errorf("Validate(non pointer) err = %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchPollInterval 无法使用inotify时轮询文件的间隔
+var WatchPollInterval = 2 * time.Second
+
+// watchDebounce 编辑器保存时会连续产生多个事件, 合并为一次加载
+const watchDebounce = 100 * time.Millisecond
+
+// Watcher 监听配置文件, 内容变化后按Load重新加载与校验, 成功才原子替换并通知订阅者, 失败保留原配置
+type Watcher[T any] struct {
+	path    string
+	current atomic.Pointer[T]
+	content []byte // 上次加载时的文件内容, 只在监听协程中读写
+
+	mu      sync.Mutex
+	subs    []func(old, new *T)
+	onError func(error)
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// Watch 将path加载到c并开始监听, onChange可为nil, 其他模块可再Subscribe.
+// onError接收重新加载失败与退化为轮询的通知, 为nil时用标准库log输出.
+// c只保存首次加载的结果, 之后的配置用Get取得; 订阅者在监听协程中依次调用, 不要阻塞
+func Watch[T any](path string, c *T, onChange func(old, new *T), onError func(error)) (*Watcher[T], error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Load(c, path); err != nil {
+		return nil, err
+	}
+	if onError == nil {
+		onError = func(err error) { log.Printf("config: watch %s: %v", path, err) }
+	}
+	w := &Watcher[T]{
+		path:    path,
+		content: content,
+		onError: onError,
+		stopCh:  make(chan struct{}),
+	}
+	w.current.Store(c)
+	if onChange != nil {
+		w.subs = append(w.subs, onChange)
+	}
+
+	fw, err := newFileWatcher(path)
+	if err != nil {
+		w.fail(fmt.Errorf("inotify failed, poll every %v: %w", WatchPollInterval, err))
+		go w.poll()
+		return w, nil
+	}
+	go w.notify(fw)
+	return w, nil
+}
+
+// newFileWatcher 监听所在目录, 编辑器与k8s ConfigMap都是先写临时文件再rename替换
+func newFileWatcher(path string) (*fsnotify.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.Add(filepath.Dir(path)); err != nil {
+		fw.Close()
+		return nil, err
+	}
+	return fw, nil
+}
+
+// Path 监听的文件
+func (w *Watcher[T]) Path() string {
+	return w.path
+}
+
+// Get 当前生效的配置, 不要修改其中的字段
+func (w *Watcher[T]) Get() *T {
+	return w.current.Load()
+}
+
+// Subscribe 配置替换后调用fn, old与new都不要修改
+func (w *Watcher[T]) Subscribe(fn func(old, new *T)) {
+	w.mu.Lock()
+	w.subs = append(w.subs, fn)
+	w.mu.Unlock()
+}
+
+// OnError 替换Watch时传入的onError
+func (w *Watcher[T]) OnError(fn func(error)) {
+	w.mu.Lock()
+	w.onError = fn
+	w.mu.Unlock()
+}
+
+// Close 停止监听, 不等待正在执行的订阅者
+func (w *Watcher[T]) Close() error {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+	return nil
+}
+
+func (w *Watcher[T]) notify(fw *fsnotify.Watcher) {
+	defer fw.Close()
+	name := filepath.Clean(w.path)
+	dir := filepath.Dir(name)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+			// 文件本身, 或软链接指向的..data目录被替换
+			if filepath.Clean(ev.Name) == name || filepath.Dir(ev.Name) == dir && filepath.Base(ev.Name) == "..data" {
+				timer.Reset(watchDebounce)
+			}
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+			w.fail(err)
+		case <-timer.C:
+			w.reload()
+		}
+	}
+}
+
+func (w *Watcher[T]) poll() {
+	ticker := time.NewTicker(WatchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+			w.reload()
+		}
+	}
+}
+
+// reload 内容没有变化时不加载; 文件暂时不存在(rename替换的间隙)时等下一次事件
+func (w *Watcher[T]) reload() {
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			w.fail(err)
+		}
+		return
+	}
+	if bytes.Equal(content, w.content) {
+		return
+	}
+	// 加载失败也记下内容, 文件再次修改前不重复报错
+	w.content = content
+	c := new(T)
+	if _, err := Load(c, w.path); err != nil {
+		w.fail(err)
+		return
+	}
+	old := w.current.Swap(c)
+
+	w.mu.Lock()
+	subs := append([]func(old, new *T){}, w.subs...)
+	w.mu.Unlock()
+	for _, fn := range subs {
+		select {
+		case <-w.stopCh:
+			return
+		default:
+		}
+		fn(old, c)
+	}
+}
+
+func (w *Watcher[T]) fail(err error) {
+	w.mu.Lock()
+	fn := w.onError
+	w.mu.Unlock()
+	if fn != nil {
+		fn(err)
+	}
+}
@@ -20,15 +20,15 @@ var ConsulInstance *ConsulUtils
 
 type SvrCfg struct {
 	Server struct {
-		ConsulAddr string `default:""`
+		ConsulAddr string `default:"" validate:"hostport"`
 	}
 }
 
+// Init 从配置文件读取Server.ConsulAddr, 配置不合法时返回列出所有错误字段的config.ValidationError
 func Init(cfg string) error {
 	svrCfg := SvrCfg{}
 
-	if err := config.ParseConfigWithPath(&svrCfg, cfg); err != nil {
-		fmt.Printf("load svrCfg failed. err:%+v cfg:%s", err, cfg)
+	if _, err := config.Load(&svrCfg, cfg); err != nil {
 		return err
 	}
 
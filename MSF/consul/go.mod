@@ -4,4 +4,11 @@ go 1.20
 
 require github.com/xiaolongdeng1990/forlife/MSF/config v0.0.0-20240423133039-064980ade61d
 
-require github.com/BurntSushi/toml v1.3.2 // indirect
+require (
+	github.com/BurntSushi/toml v1.3.2 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/xiaolongdeng1990/forlife/MSF/config => ../config
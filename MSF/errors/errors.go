@@ -0,0 +1,175 @@
+package flerrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Code 框架统一错误码, 服务端与客户端共用
+type Code int32
+
+const (
+	OK               Code = 0
+	Unknown          Code = 1  // 未带错误码的业务错误
+	InvalidArgument  Code = 2  // 请求参数非法
+	NotFound         Code = 3  // 资源不存在
+	Timeout          Code = 4  // 超时
+	Unauthenticated  Code = 5  // 未认证
+	PermissionDenied Code = 6  // 无权限
+	RateLimited      Code = 7  // 被限流
+	Unavailable      Code = 8  // 下游不可用: 无可用节点、连接失败、熔断等
+	Internal         Code = 9  // 服务内部错误, 如panic
+	Canceled         Code = 10 // 调用方取消
+)
+
+var codeNames = map[Code]string{
+	OK:               "OK",
+	Unknown:          "Unknown",
+	InvalidArgument:  "InvalidArgument",
+	NotFound:         "NotFound",
+	Timeout:          "Timeout",
+	Unauthenticated:  "Unauthenticated",
+	PermissionDenied: "PermissionDenied",
+	RateLimited:      "RateLimited",
+	Unavailable:      "Unavailable",
+	Internal:         "Internal",
+	Canceled:         "Canceled",
+}
+
+// String 错误码名称
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "Code(" + strconv.Itoa(int(c)) + ")"
+}
+
+// rpcx元数据中携带错误的key
+const (
+	MetaCode    = "__fl_err_code"
+	MetaMsg     = "__fl_err_msg"
+	MetaDetails = "__fl_err_details"
+)
+
+// Error 带错误码的错误
+type Error struct {
+	Code    Code
+	Msg     string
+	Details map[string]string
+	cause   error
+}
+
+// New 创建错误
+func New(code Code, msg string) *Error {
+	return &Error{Code: code, Msg: msg}
+}
+
+// Newf 创建错误, msg按printf格式化
+func Newf(code Code, f string, p ...interface{}) *Error {
+	return &Error{Code: code, Msg: fmt.Sprintf(f, p...)}
+}
+
+// Wrap 给已有错误附加错误码, errors.Unwrap可取回原错误, 原错误不会传给调用方
+func Wrap(err error, code Code, msg string) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Msg: msg, cause: err}
+}
+
+// WithDetail 追加一条详情, 返回自身便于链式调用
+func (e *Error) WithDetail(key, value string) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]string)
+	}
+	e.Details[key] = value
+	return e
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 错误码相同即视为同一错误, target带Msg时还需Msg相同, 如 errors.Is(err, flerrors.New(flerrors.NotFound, ""))
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && (len(t.Msg) == 0 || t.Msg == e.Msg)
+}
+
+// CodeOf 取错误码: nil为OK, ctx超时/取消映射为Timeout/Canceled, 其余未带码的为Unknown
+func CodeOf(err error) Code {
+	if err == nil {
+		return OK
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	}
+	return Unknown
+}
+
+// FromError 转为*Error, 未带码的错误按CodeOf的规则包装
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Wrap(err, CodeOf(err), err.Error())
+}
+
+// IsRetryable 是否值得重试: 下游不可用、超时与限流
+func IsRetryable(err error) bool {
+	switch CodeOf(err) {
+	case Unavailable, Timeout, RateLimited:
+		return true
+	}
+	return false
+}
+
+// ToMetadata 将错误写入rpcx元数据, 供服务端回传给调用方
+func ToMetadata(err error, meta map[string]string) {
+	if err == nil || meta == nil {
+		return
+	}
+	e := FromError(err)
+	meta[MetaCode] = strconv.Itoa(int(e.Code))
+	meta[MetaMsg] = e.Msg
+	if len(e.Details) > 0 {
+		if details, jerr := json.Marshal(e.Details); jerr == nil {
+			meta[MetaDetails] = string(details)
+		}
+	}
+}
+
+// FromMetadata 从rpcx元数据还原错误, 元数据中没有错误码时返回nil
+func FromMetadata(meta map[string]string) *Error {
+	codeStr, ok := meta[MetaCode]
+	if !ok {
+		return nil
+	}
+	code, err := strconv.Atoi(codeStr)
+	if err != nil {
+		return nil
+	}
+	e := New(Code(code), meta[MetaMsg])
+	if details := meta[MetaDetails]; len(details) > 0 {
+		_ = json.Unmarshal([]byte(details), &e.Details)
+	}
+	return e
+}
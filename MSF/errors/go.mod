@@ -0,0 +1,3 @@
+module github.com/xiaolongdeng1990/forlife/MSF/errors
+
+go 1.20
@@ -0,0 +1,174 @@
+package fllog
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 异步队列满时的处理方式
+const (
+	OverflowBlock      = "block"       // 等待队列有空位, 不丢日志
+	OverflowDropOldest = "drop_oldest" // 丢弃队列中最早的一条
+	OverflowDropNewest = "drop_newest" // 丢弃当前这条
+)
+
+const defaultAsyncBufferSize = 8192
+
+// AsyncCfg 异步写日志, 调用方只把编码好的日志放入队列, 由后台协程写文件/网络
+type AsyncCfg struct {
+	Enable     bool   `default:"false"`
+	BufferSize int    `default:"8192" validate:"min=1"`                                // 队列能容纳的日志条数
+	Overflow   string `default:"block" validate:"oneof=block drop_oldest drop_newest"` // 队列满时的处理方式
+}
+
+var asyncDropped uint64
+
+// errAsyncClosed Close之后底层输出已关闭, 不能再写
+var errAsyncClosed = errors.New("fllog: async writer closed")
+
+// AsyncDropped 异步队列满时丢弃的日志条数
+func AsyncDropped() uint64 {
+	return atomic.LoadUint64(&asyncDropped)
+}
+
+// asyncWriter 有界环形队列, 后台协程按顺序写入w
+type asyncWriter struct {
+	w        zapcore.WriteSyncer
+	closer   io.Closer
+	overflow string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   [][]byte
+	head    int
+	n       int
+	writing bool
+	closed  bool
+	done    chan struct{}
+}
+
+func newAsyncWriter(w zapcore.WriteSyncer, closer io.Closer, cfg AsyncCfg) *asyncWriter {
+	size := cfg.BufferSize
+	if size <= 0 {
+		size = defaultAsyncBufferSize
+	}
+	overflow := strings.ToLower(cfg.Overflow)
+	if len(overflow) == 0 {
+		overflow = OverflowBlock
+	}
+	a := &asyncWriter{
+		w:        w,
+		closer:   closer,
+		overflow: overflow,
+		queue:    make([][]byte, size),
+		done:     make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+// Write zap在返回后会复用p, 这里复制一份入队; Close之后返回errAsyncClosed, 不再写已关闭的输出
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	for {
+		// 等待期间可能已Close, 每次醒来都要检查
+		if a.closed {
+			a.mu.Unlock()
+			return 0, errAsyncClosed
+		}
+		if a.n < len(a.queue) {
+			break
+		}
+		switch a.overflow {
+		case OverflowDropNewest:
+			a.mu.Unlock()
+			atomic.AddUint64(&asyncDropped, 1)
+			return len(p), nil
+		case OverflowDropOldest:
+			a.queue[a.head] = nil
+			a.head = (a.head + 1) % len(a.queue)
+			a.n--
+			atomic.AddUint64(&asyncDropped, 1)
+		default:
+			a.cond.Wait()
+		}
+	}
+	a.queue[(a.head+a.n)%len(a.queue)] = append([]byte(nil), p...)
+	a.n++
+	a.mu.Unlock()
+	a.cond.Broadcast()
+	return len(p), nil
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.done)
+	batch := make([][]byte, 0, 64)
+	for {
+		a.mu.Lock()
+		for a.n == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if a.n == 0 && a.closed {
+			a.mu.Unlock()
+			return
+		}
+		for a.n > 0 {
+			batch = append(batch, a.queue[a.head])
+			a.queue[a.head] = nil
+			a.head = (a.head + 1) % len(a.queue)
+			a.n--
+		}
+		a.writing = true
+		a.mu.Unlock()
+		a.cond.Broadcast()
+
+		for i, p := range batch {
+			a.w.Write(p)
+			batch[i] = nil
+		}
+		batch = batch[:0]
+
+		a.mu.Lock()
+		a.writing = false
+		a.mu.Unlock()
+		a.cond.Broadcast()
+	}
+}
+
+// Sync 等队列中的日志写完再Sync底层输出, Close之后不再操作底层输出
+func (a *asyncWriter) Sync() error {
+	a.mu.Lock()
+	for (a.n > 0 || a.writing) && !a.closed {
+		a.cond.Wait()
+	}
+	closed := a.closed
+	a.mu.Unlock()
+	if closed {
+		return nil
+	}
+	return a.w.Sync()
+}
+
+// Close 写完队列中的日志后关闭底层输出
+func (a *asyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.mu.Unlock()
+	a.cond.Broadcast()
+	<-a.done
+	a.w.Sync()
+	if a.closer != nil {
+		return a.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package fllog
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSink 第一次Write阻塞到release关闭, 记录Close之后的写入
+type blockingSink struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	closed  bool
+	late    int
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingSink) Write(p []byte) (int, error) {
+	s.once.Do(func() {
+		close(s.started)
+		<-s.release
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.late++
+		return 0, errors.New("write after close")
+	}
+	return s.buf.Write(p)
+}
+
+func (s *blockingSink) Sync() error { return nil }
+
+func (s *blockingSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+func TestAsyncWriterClose(t *testing.T) {
+	sink := &blockingSink{started: make(chan struct{}), release: make(chan struct{})}
+	a := newAsyncWriter(sink, sink, AsyncCfg{BufferSize: 1, Overflow: OverflowBlock})
+
+	// 后台协程卡在第一条, 第二条占满队列, 第三条阻塞等待空位
+	a.Write([]byte("1\n"))
+	<-sink.started
+	a.Write([]byte("2\n"))
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := a.Write([]byte("3\n"))
+		blocked <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() { closed <- a.Close() }()
+	time.Sleep(20 * time.Millisecond)
+	close(sink.release)
+
+	if err := <-closed; err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := <-blocked; err != nil && !errors.Is(err, errAsyncClosed) {
+		t.Errorf("blocked Write: %v", err)
+	}
+	if _, err := a.Write([]byte("4\n")); !errors.Is(err, errAsyncClosed) {
+		t.Errorf("Write after Close err = %v, want errAsyncClosed", err)
+	}
+	if err := a.Sync(); err != nil {
+		t.Errorf("Sync after Close: %v", err)
+	}
+	if sink.late != 0 {
+		t.Errorf("%d writes reached the closed sink", sink.late)
+	}
+	if got := sink.buf.String(); got != "1\n2\n" && got != "1\n2\n3\n" {
+		t.Errorf("written %q", got)
+	}
+}
@@ -10,6 +10,19 @@ type BuilderInterface interface {
 	SetLogFileName(logFileName string) BuilderInterface
 	GetLogFileName() string
 	SetMaxSize(MaxSize int) BuilderInterface
+	GetMaxSize() int
 	SetMaxAge(MaxAge int) BuilderInterface
+	GetMaxAge() int
 	SetMaxBackups(MaxBackups int) BuilderInterface
+	GetMaxBackups() int
+	SetCompress(compress bool) BuilderInterface
+	GetCompress() bool
+	SetLocalTime(localTime bool) BuilderInterface
+	GetLocalTime() bool
+	SetRotate(rotate string) BuilderInterface
+	GetRotate() string
+	SetSinks(sinks []SinkCfg) BuilderInterface
+	GetSinks() []SinkCfg
+	SetAsync(async AsyncCfg) BuilderInterface
+	GetAsync() AsyncCfg
 }
@@ -0,0 +1,84 @@
+package fllog
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// 框架自动放入ctx的日志字段
+const (
+	FieldTraceID   = "trace_id"
+	FieldSpanID    = "span_id"
+	FieldRequestID = "request_id"
+	FieldService   = "service"
+	FieldMethod    = "method"
+)
+
+type fieldsKey struct{}
+
+// NewContext 返回附带日志字段的ctx, 用该ctx打印的日志都会带上这些字段, 如trace_id.
+// kv为交替的key/value, 与ctx已有字段同名时覆盖
+func NewContext(ctx context.Context, kv ...interface{}) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, mergeFields(FieldsFromContext(ctx), kv))
+}
+
+// FieldsFromContext 取ctx中的日志字段
+func FieldsFromContext(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	return fields
+}
+
+// ContextValue 取ctx中名为key的日志字段, 没有时返回空串
+func ContextValue(ctx context.Context, key string) string {
+	fields := FieldsFromContext(ctx)
+	for i := 0; i+1 < len(fields); i += 2 {
+		if k, ok := fields[i].(string); ok && k == key {
+			if v, ok := fields[i+1].(string); ok {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// Ctx 返回带有ctx中日志字段的logger
+func Ctx(ctx context.Context) *zap.SugaredLogger {
+	logger := Log()
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		return logger.With(fields...)
+	}
+	return logger
+}
+
+// mergeFields 合并两组key/value, 后者同名时覆盖前者并保持前者的位置, 不修改入参
+func mergeFields(base, kv []interface{}) []interface{} {
+	if len(kv) == 0 {
+		return base
+	}
+	fields := make([]interface{}, 0, len(base)+len(kv))
+	fields = append(fields, base...)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			// 落单的key交给zap按其规则处理
+			fields = append(fields, kv[i])
+			break
+		}
+		key, isStr := kv[i].(string)
+		replaced := false
+		for j := 0; isStr && j+1 < len(fields); j += 2 {
+			if k, ok := fields[j].(string); ok && k == key {
+				fields[j+1] = kv[i+1]
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			fields = append(fields, kv[i], kv[i+1])
+		}
+	}
+	return fields
+}
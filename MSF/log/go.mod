@@ -4,7 +4,6 @@ go 1.20
 
 require (
 	github.com/fatih/color v1.16.0
-	github.com/wk8/go-ordered-map v1.0.0
 	github.com/xiaolongdeng1990/forlife/MSF/config v0.0.0-20240420121952-aea7746477b6
 	go.uber.org/zap v1.27.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
@@ -12,8 +11,12 @@ require (
 
 require (
 	github.com/BurntSushi/toml v1.3.2 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
 	golang.org/x/sys v0.14.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/xiaolongdeng1990/forlife/MSF/config => ../config
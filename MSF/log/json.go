@@ -0,0 +1,326 @@
+package fllog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	jsonPool        = buffer.NewPool()
+	jsonEncoderPool = sync.Pool{New: func() interface{} { return &jsonEncoder{} }}
+)
+
+// jsonEncoder 按caller/time/level/name/msg在前, With字段与本条字段按添加顺序在后输出一行JSON.
+// 所有内容直接写入同一个buffer, 不经过zap的编码器再拷贝, 也不反序列化重排
+type jsonEncoder struct {
+	cfg            *zapcore.EncoderConfig
+	buf            *buffer.Buffer // With添加的已编码字段; EncodeEntry时为输出行
+	openNamespaces int
+}
+
+func newJSONEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &jsonEncoder{cfg: &cfg, buf: jsonPool.Get()}
+}
+
+func (e *jsonEncoder) Clone() zapcore.Encoder {
+	c := &jsonEncoder{cfg: e.cfg, buf: jsonPool.Get(), openNamespaces: e.openNamespaces}
+	c.buf.Write(e.buf.Bytes())
+	return c
+}
+
+func (e *jsonEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := jsonEncoderPool.Get().(*jsonEncoder)
+	final.cfg, final.buf, final.openNamespaces = e.cfg, jsonPool.Get(), 0
+	defer func() {
+		final.cfg, final.buf = nil, nil
+		jsonEncoderPool.Put(final)
+	}()
+
+	cfg := e.cfg
+	final.buf.AppendByte('{')
+	// 自定义的caller/time/level编码器写多个值时以逗号分隔, 与zap一致; 未写值时为null
+	if len(cfg.CallerKey) > 0 && ent.Caller.Defined && cfg.EncodeCaller != nil {
+		final.addKey(cfg.CallerKey)
+		cur := final.buf.Len()
+		cfg.EncodeCaller(ent.Caller, final)
+		final.nullIfEmpty(cur)
+	}
+	if len(cfg.TimeKey) > 0 && cfg.EncodeTime != nil {
+		final.addKey(cfg.TimeKey)
+		cur := final.buf.Len()
+		cfg.EncodeTime(ent.Time, final)
+		final.nullIfEmpty(cur)
+	}
+	if len(cfg.LevelKey) > 0 && cfg.EncodeLevel != nil {
+		final.addKey(cfg.LevelKey)
+		cur := final.buf.Len()
+		cfg.EncodeLevel(ent.Level, final)
+		final.nullIfEmpty(cur)
+	}
+	if len(cfg.NameKey) > 0 && len(ent.LoggerName) > 0 {
+		final.AddString(cfg.NameKey, ent.LoggerName)
+	}
+	if len(cfg.MessageKey) > 0 {
+		final.AddString(cfg.MessageKey, ent.Message)
+	}
+	if e.buf.Len() > 0 {
+		final.addElementSeparator()
+		final.buf.Write(e.buf.Bytes())
+	}
+	final.openNamespaces = e.openNamespaces
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+	final.closeOpenNamespaces()
+	if len(ent.Stack) > 0 && len(cfg.StacktraceKey) > 0 {
+		final.AddString(cfg.StacktraceKey, ent.Stack)
+	}
+	final.buf.AppendByte('}')
+	if len(cfg.LineEnding) > 0 {
+		final.buf.AppendString(cfg.LineEnding)
+	} else {
+		final.buf.AppendString(zapcore.DefaultLineEnding)
+	}
+	return final.buf, nil
+}
+
+func (e *jsonEncoder) nullIfEmpty(cur int) {
+	if e.buf.Len() == cur {
+		e.buf.AppendString("null")
+	}
+}
+
+func (e *jsonEncoder) addKey(key string) {
+	e.addElementSeparator()
+	appendJSONString(e.buf, key)
+	e.buf.AppendByte(':')
+}
+
+func (e *jsonEncoder) addElementSeparator() {
+	last := e.buf.Len() - 1
+	if last < 0 {
+		return
+	}
+	switch e.buf.Bytes()[last] {
+	case '{', '[', ':', ',':
+		return
+	}
+	e.buf.AppendByte(',')
+}
+
+func (e *jsonEncoder) closeOpenNamespaces() {
+	for i := 0; i < e.openNamespaces; i++ {
+		e.buf.AppendByte('}')
+	}
+	e.openNamespaces = 0
+}
+
+// ObjectEncoder
+
+func (e *jsonEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	e.addKey(key)
+	return e.AppendArray(arr)
+}
+
+func (e *jsonEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	e.addKey(key)
+	return e.AppendObject(obj)
+}
+
+func (e *jsonEncoder) AddBinary(key string, v []byte) {
+	e.AddString(key, base64.StdEncoding.EncodeToString(v))
+}
+
+func (e *jsonEncoder) AddByteString(key string, v []byte)     { e.addKey(key); e.AppendByteString(v) }
+func (e *jsonEncoder) AddBool(key string, v bool)             { e.addKey(key); e.AppendBool(v) }
+func (e *jsonEncoder) AddComplex128(key string, v complex128) { e.addKey(key); e.AppendComplex128(v) }
+func (e *jsonEncoder) AddComplex64(key string, v complex64)   { e.addKey(key); e.AppendComplex64(v) }
+func (e *jsonEncoder) AddDuration(key string, v time.Duration) {
+	e.addKey(key)
+	e.AppendDuration(v)
+}
+func (e *jsonEncoder) AddFloat64(key string, v float64) { e.addKey(key); e.AppendFloat64(v) }
+func (e *jsonEncoder) AddFloat32(key string, v float32) { e.addKey(key); e.AppendFloat32(v) }
+func (e *jsonEncoder) AddInt(key string, v int)         { e.addKey(key); e.AppendInt64(int64(v)) }
+func (e *jsonEncoder) AddInt64(key string, v int64)     { e.addKey(key); e.AppendInt64(v) }
+func (e *jsonEncoder) AddInt32(key string, v int32)     { e.addKey(key); e.AppendInt64(int64(v)) }
+func (e *jsonEncoder) AddInt16(key string, v int16)     { e.addKey(key); e.AppendInt64(int64(v)) }
+func (e *jsonEncoder) AddInt8(key string, v int8)       { e.addKey(key); e.AppendInt64(int64(v)) }
+func (e *jsonEncoder) AddString(key, v string)          { e.addKey(key); e.AppendString(v) }
+func (e *jsonEncoder) AddTime(key string, v time.Time)  { e.addKey(key); e.AppendTime(v) }
+func (e *jsonEncoder) AddUint(key string, v uint)       { e.addKey(key); e.AppendUint64(uint64(v)) }
+func (e *jsonEncoder) AddUint64(key string, v uint64)   { e.addKey(key); e.AppendUint64(v) }
+func (e *jsonEncoder) AddUint32(key string, v uint32)   { e.addKey(key); e.AppendUint64(uint64(v)) }
+func (e *jsonEncoder) AddUint16(key string, v uint16)   { e.addKey(key); e.AppendUint64(uint64(v)) }
+func (e *jsonEncoder) AddUint8(key string, v uint8)     { e.addKey(key); e.AppendUint64(uint64(v)) }
+func (e *jsonEncoder) AddUintptr(key string, v uintptr) { e.addKey(key); e.AppendUint64(uint64(v)) }
+
+func (e *jsonEncoder) AddReflected(key string, v interface{}) error {
+	e.addKey(key)
+	return e.AppendReflected(v)
+}
+
+func (e *jsonEncoder) OpenNamespace(key string) {
+	e.addKey(key)
+	e.buf.AppendByte('{')
+	e.openNamespaces++
+}
+
+// ArrayEncoder
+
+func (e *jsonEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	e.addElementSeparator()
+	e.buf.AppendByte('[')
+	err := arr.MarshalLogArray(e)
+	e.buf.AppendByte(']')
+	return err
+}
+
+func (e *jsonEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	// 对象内的namespace在对象结束时关闭
+	old := e.openNamespaces
+	e.openNamespaces = 0
+	e.addElementSeparator()
+	e.buf.AppendByte('{')
+	err := obj.MarshalLogObject(e)
+	e.closeOpenNamespaces()
+	e.buf.AppendByte('}')
+	e.openNamespaces = old
+	return err
+}
+
+// AppendReflected 无法用类型化字段表示的值用encoding/json编码, 不转义HTML
+func (e *jsonEncoder) AppendReflected(v interface{}) error {
+	rb := jsonPool.Get()
+	defer rb.Free()
+	enc := json.NewEncoder(rb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	e.addElementSeparator()
+	e.buf.Write(bytes.TrimSuffix(rb.Bytes(), []byte("\n")))
+	return nil
+}
+
+func (e *jsonEncoder) AppendBool(v bool) { e.addElementSeparator(); e.buf.AppendBool(v) }
+func (e *jsonEncoder) AppendByteString(v []byte) {
+	e.addElementSeparator()
+	appendJSONString(e.buf, string(v))
+}
+func (e *jsonEncoder) AppendComplex128(v complex128) {
+	e.addElementSeparator()
+	appendJSONString(e.buf, strconv.FormatComplex(v, 'g', -1, 128))
+}
+func (e *jsonEncoder) AppendComplex64(v complex64) {
+	e.addElementSeparator()
+	appendJSONString(e.buf, strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+
+// AppendDuration 未配置EncodeDuration或其未写值时输出纳秒数
+func (e *jsonEncoder) AppendDuration(v time.Duration) {
+	cur := e.buf.Len()
+	if e.cfg != nil && e.cfg.EncodeDuration != nil {
+		e.cfg.EncodeDuration(v, e)
+	}
+	if e.buf.Len() == cur {
+		e.AppendInt64(int64(v))
+	}
+}
+
+// AppendTime 未配置EncodeTime或其未写值时输出UnixNano
+func (e *jsonEncoder) AppendTime(v time.Time) {
+	cur := e.buf.Len()
+	if e.cfg != nil && e.cfg.EncodeTime != nil {
+		e.cfg.EncodeTime(v, e)
+	}
+	if e.buf.Len() == cur {
+		e.AppendInt64(v.UnixNano())
+	}
+}
+
+func (e *jsonEncoder) AppendFloat64(v float64) {
+	e.addElementSeparator()
+	appendJSONFloat(e.buf, v, 64)
+}
+func (e *jsonEncoder) AppendFloat32(v float32) {
+	e.addElementSeparator()
+	appendJSONFloat(e.buf, float64(v), 32)
+}
+func (e *jsonEncoder) AppendInt(v int)         { e.AppendInt64(int64(v)) }
+func (e *jsonEncoder) AppendInt64(v int64)     { e.addElementSeparator(); e.buf.AppendInt(v) }
+func (e *jsonEncoder) AppendInt32(v int32)     { e.AppendInt64(int64(v)) }
+func (e *jsonEncoder) AppendInt16(v int16)     { e.AppendInt64(int64(v)) }
+func (e *jsonEncoder) AppendInt8(v int8)       { e.AppendInt64(int64(v)) }
+func (e *jsonEncoder) AppendString(v string)   { e.addElementSeparator(); appendJSONString(e.buf, v) }
+func (e *jsonEncoder) AppendUint(v uint)       { e.AppendUint64(uint64(v)) }
+func (e *jsonEncoder) AppendUint64(v uint64)   { e.addElementSeparator(); e.buf.AppendUint(v) }
+func (e *jsonEncoder) AppendUint32(v uint32)   { e.AppendUint64(uint64(v)) }
+func (e *jsonEncoder) AppendUint16(v uint16)   { e.AppendUint64(uint64(v)) }
+func (e *jsonEncoder) AppendUint8(v uint8)     { e.AppendUint64(uint64(v)) }
+func (e *jsonEncoder) AppendUintptr(v uintptr) { e.AppendUint64(uint64(v)) }
+
+// appendJSONFloat NaN与Inf不是合法JSON数字, 以字符串输出
+func appendJSONFloat(buf *buffer.Buffer, v float64, bitSize int) {
+	s := strconv.FormatFloat(v, 'g', -1, bitSize)
+	if s == "NaN" || s == "+Inf" || s == "-Inf" {
+		appendJSONString(buf, s)
+		return
+	}
+	buf.AppendString(s)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString 加引号并转义, 非法UTF-8替换为�
+func appendJSONString(buf *buffer.Buffer, s string) {
+	buf.AppendByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf.AppendString(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.AppendByte('\\')
+				buf.AppendByte(c)
+			case '\n':
+				buf.AppendString(`\n`)
+			case '\r':
+				buf.AppendString(`\r`)
+			case '\t':
+				buf.AppendString(`\t`)
+			default:
+				buf.AppendString(`\u00`)
+				buf.AppendByte(hexDigits[c>>4])
+				buf.AppendByte(hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.AppendString(s[start:i])
+			buf.AppendString(`�`)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.AppendString(s[start:])
+	buf.AppendByte('"')
+}
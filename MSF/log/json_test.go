@@ -0,0 +1,163 @@
+package fllog
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// legacyJSONEncoder 改写前的CustomEncoder: zap编码后反序列化为map, 按caller/time/level重排再序列化.
+// 原实现的有序map依赖已删除, 这里按同样的步骤手工输出, 只作为基准对比
+type legacyJSONEncoder struct {
+	zapcore.Encoder
+}
+
+func (e *legacyJSONEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &obj); err != nil {
+		return nil, err
+	}
+	keys := []string{"line", "time", "level"}
+	for k := range obj {
+		if k != "line" && k != "time" && k != "level" {
+			keys = append(keys, k)
+		}
+	}
+	buf.Reset()
+	buf.AppendByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(obj[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.AppendByte(':')
+		buf.Write(vb)
+	}
+	buf.AppendByte('}')
+	return buf, nil
+}
+
+func benchEntry() (zapcore.Entry, []zapcore.Field) {
+	ent := zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       time.Date(2024, 4, 20, 12, 0, 0, 0, time.UTC),
+		LoggerName: "client",
+		Message:    "call",
+		Caller:     zapcore.NewEntryCaller(0, "/src/forlife/MSF/client/interceptor.go", 76, true),
+	}
+	fields := []zapcore.Field{
+		zap.String("callee", "Demo.Add"),
+		zap.Int64("req_id", 1<<60+1),
+		zap.Duration("cost", 1500*time.Microsecond),
+		zap.Bool("retry", false),
+		zap.String("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"),
+	}
+	return ent, fields
+}
+
+func TestJSONEncoder(t *testing.T) {
+	enc := newJSONEncoder(NewLogUtils().fileEncoderConfig())
+	ctx := enc.Clone()
+	ctx.AddString("service", "demo")
+	ctx.OpenNamespace("req")
+
+	ent, fields := benchEntry()
+	fields = append(fields,
+		zap.Float64("nan", math.NaN()),
+		zap.Strings("tags", []string{"a", "b\"c"}),
+		zap.Error(errors.New("boom\n")),
+		zap.Any("obj", map[string]int{"x": 1}),
+	)
+	buf, err := ctx.EncodeEntry(ent, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := buf.String()
+	buf.Free()
+
+	if !strings.HasSuffix(line, "}\n") {
+		t.Fatalf("want one line ending with newline, got %q", line)
+	}
+	if !json.Valid([]byte(line)) {
+		t.Fatalf("invalid json: %s", line)
+	}
+	wantPrefix := `{"line":"[client/interceptor.go:76]","time":"[2024-04-20 12:00:00]","level":"info","name":"client","msg":"call","service":"demo","req":{"callee":"Demo.Add","req_id":1152921504606846977,`
+	if !strings.HasPrefix(line, wantPrefix) {
+		t.Errorf("field order:\n got %s\nwant prefix %s", line, wantPrefix)
+	}
+	for _, want := range []string{`"cost":0.0015`, `"nan":"NaN"`, `"tags":["a","b\"c"]`, `"error":"boom\n"`, `"obj":{"x":1}}}`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("missing %s in %s", want, line)
+		}
+	}
+
+	// With之后的encoder不受EncodeEntry影响, 可重复使用
+	buf, _ = ctx.EncodeEntry(zapcore.Entry{Message: "again"}, nil)
+	if got := buf.String(); !json.Valid(buf.Bytes()) || !strings.Contains(got, `"service":"demo","req":{}`) {
+		t.Errorf("reuse: %s", got)
+	}
+	buf.Free()
+}
+
+func BenchmarkJSONEncoder(b *testing.B) {
+	enc := newJSONEncoder(NewLogUtils().fileEncoderConfig())
+	enc.AddString("service", "demo")
+	ent, fields := benchEntry()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, err := enc.EncodeEntry(ent, fields)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf.Free()
+	}
+}
+
+func BenchmarkLegacyJSONEncoder(b *testing.B) {
+	enc := &legacyJSONEncoder{zapcore.NewJSONEncoder(NewLogUtils().fileEncoderConfig())}
+	enc.AddString("service", "demo")
+	ent, fields := benchEntry()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, err := enc.EncodeEntry(ent, fields)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf.Free()
+	}
+}
+
+// BenchmarkLoggerInfo 经Named logger输出到丢弃的JSON sink, 包含字段合并、caller与编码的全部开销
+func BenchmarkLoggerInfo(b *testing.B) {
+	utils := NewLogUtils()
+	old := utils.skipLogger.Load()
+	defer utils.skipLogger.Store(old)
+	core := zapcore.NewCore(newJSONEncoder(utils.fileEncoderConfig()), zapcore.AddSync(io.Discard), TraceLevel)
+	utils.skipLogger.Store(zap.New(core, zap.AddCaller(), zap.AddCallerSkip(2)))
+
+	l := Named("bench").With("service", "demo")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("call", "callee", "Demo.Add", "cost", i)
+	}
+}
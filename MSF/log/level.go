@@ -0,0 +1,394 @@
+package fllog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/xiaolongdeng1990/forlife/MSF/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levels 全局级别与按模块(logger名)的覆盖, 运行中可修改, 重新Init不会丢失模块覆盖
+type levels struct {
+	global zap.AtomicLevel
+
+	mu      sync.RWMutex
+	modules map[string]zapcore.Level
+}
+
+func newLevels() *levels {
+	return &levels{global: zap.NewAtomicLevel(), modules: make(map[string]zapcore.Level)}
+}
+
+// enabled name按"."分级, 取最长匹配的模块级别, 如client.retry先找client.retry再找client
+func (lv *levels) enabled(name string, l zapcore.Level) bool {
+	lv.mu.RLock()
+	defer lv.mu.RUnlock()
+	for len(name) > 0 && len(lv.modules) > 0 {
+		if min, ok := lv.modules[name]; ok {
+			return l >= min
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return lv.global.Enabled(l)
+}
+
+// anyEnabled 全局或任一模块会输出该级别
+func (lv *levels) anyEnabled(l zapcore.Level) bool {
+	if lv.global.Enabled(l) {
+		return true
+	}
+	lv.mu.RLock()
+	defer lv.mu.RUnlock()
+	for _, min := range lv.modules {
+		if l >= min {
+			return true
+		}
+	}
+	return false
+}
+
+// levelCore 在各输出之前按logger名过滤级别
+type levelCore struct {
+	zapcore.Core
+	levels *levels
+}
+
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return c.levels.anyEnabled(l)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.enabled(ent.LoggerName, ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// TraceLevel zap没有TRACE, 取比DEBUG低一级
+const TraceLevel = zapcore.DebugLevel - 1
+
+// zapLevel config.LogLevel到zap级别的唯一映射, 过滤、zap core与toml解析共用
+func zapLevel(l config.LogLevel) (zapcore.Level, error) {
+	switch l {
+	case config.LogLevelTrace:
+		return TraceLevel, nil
+	case config.LogLevelDebug:
+		return zapcore.DebugLevel, nil
+	case config.LogLevelInfo:
+		return zapcore.InfoLevel, nil
+	case config.LogLevelWarning:
+		return zapcore.WarnLevel, nil
+	case config.LogLevelError:
+		return zapcore.ErrorLevel, nil
+	case config.LogLevelFatal:
+		return zapcore.FatalLevel, nil
+	}
+	return zapcore.InfoLevel, fmt.Errorf("not support log level %v", l.Value())
+}
+
+// levelName zap级别的小写名, TRACE为trace
+func levelName(l zapcore.Level) string {
+	if l == TraceLevel {
+		return "trace"
+	}
+	return l.String()
+}
+
+func parseLevel(level string) (zapcore.Level, error) {
+	l, err := config.ParseLogLevel(level)
+	if err != nil {
+		return zapcore.InfoLevel, err
+	}
+	return zapLevel(l)
+}
+
+// SetLevel 运行中修改全局日志级别, 如"DEBUG"
+func SetLevel(level string) error {
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	NewLogUtils().levels.global.SetLevel(l)
+	return nil
+}
+
+// GetLevel 当前全局日志级别
+func GetLevel() string {
+	return strings.ToUpper(levelName(NewLogUtils().levels.global.Level()))
+}
+
+// SetModuleLevel 单独设置某个模块(Named的logger名)的级别, level为空时取消覆盖
+func SetModuleLevel(module, level string) error {
+	lv := NewLogUtils().levels
+	if len(level) == 0 {
+		lv.mu.Lock()
+		delete(lv.modules, module)
+		lv.mu.Unlock()
+		return nil
+	}
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	lv.mu.Lock()
+	lv.modules[module] = l
+	lv.mu.Unlock()
+	return nil
+}
+
+// ModuleLevels 当前所有模块级别覆盖
+func ModuleLevels() map[string]string {
+	lv := NewLogUtils().levels
+	lv.mu.RLock()
+	defer lv.mu.RUnlock()
+	modules := make(map[string]string, len(lv.modules))
+	for name, l := range lv.modules {
+		modules[name] = strings.ToUpper(levelName(l))
+	}
+	return modules
+}
+
+// setModuleLevels 用配置整体替换模块覆盖
+func setModuleLevels(modules map[string]config.LogLevel) error {
+	parsed, err := parseModuleLevels(modules)
+	if err != nil {
+		return err
+	}
+	storeModuleLevels(parsed)
+	return nil
+}
+
+// parseModuleLevels 任一模块级别非法时返回错误
+func parseModuleLevels(modules map[string]config.LogLevel) (map[string]zapcore.Level, error) {
+	parsed := make(map[string]zapcore.Level, len(modules))
+	for name, level := range modules {
+		l, err := zapLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		parsed[name] = l
+	}
+	return parsed, nil
+}
+
+func storeModuleLevels(modules map[string]zapcore.Level) {
+	lv := NewLogUtils().levels
+	lv.mu.Lock()
+	lv.modules = modules
+	lv.mu.Unlock()
+}
+
+type levelBody struct {
+	Level   string            `json:"level,omitempty"`
+	Module  string            `json:"module,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// LevelHandler 查看/修改日志级别的HTTP接口.
+// GET返回当前级别; PUT/POST {"level":"DEBUG"}改全局, {"module":"client","level":"DEBUG"}改模块, level为空取消模块覆盖
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var body levelBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var err error
+			if len(body.Module) > 0 {
+				err = SetModuleLevel(body.Module, body.Level)
+			} else {
+				err = SetLevel(body.Level)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			Warn("log level changed by %s. module:%s level:%s", r.RemoteAddr, body.Module, body.Level)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelBody{Level: GetLevel(), Modules: ModuleLevels()})
+	})
+}
+
+var (
+	adminMu  sync.Mutex
+	adminSvr *http.Server
+	cfgPath  string
+	hupOnce  sync.Once
+
+	cfgWatcher *config.Watcher[LogCfg]
+)
+
+// serveAdmin 在addr上提供/log/level, addr与正在运行的相同时不重复启动
+func serveAdmin(addr string) {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	if adminSvr != nil {
+		if adminSvr.Addr == addr {
+			return
+		}
+		_ = adminSvr.Close()
+		adminSvr = nil
+	}
+	if len(addr) == 0 {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/log/level", LevelHandler())
+	svr := &http.Server{Addr: addr, Handler: mux}
+	adminSvr = svr
+	go func() {
+		if err := svr.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			Error("log admin serve failed. addr:%s err:%v", addr, err)
+		}
+	}()
+}
+
+// Reload 重新读取Init时的配置文件, 只更新Level与Modules, 供SIGHUP或配置文件监听调用
+func Reload() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	adminMu.Lock()
+	path := cfgPath
+	adminMu.Unlock()
+	if len(path) == 0 {
+		return fmt.Errorf("fllog not init")
+	}
+	logCfg := LogCfg{}
+	if _, err := config.Load(&logCfg, path); err != nil {
+		return err
+	}
+	return applyLevels(logCfg)
+}
+
+func applyLevels(logCfg LogCfg) error {
+	lv, err := parseCfgLevels(logCfg)
+	if err != nil {
+		return err
+	}
+	lv.apply()
+	return nil
+}
+
+// cfgLevels 配置中的全局与模块级别, 全部校验通过后再生效
+type cfgLevels struct {
+	level   string
+	modules map[string]zapcore.Level
+}
+
+// parseCfgLevels 校验Level与Modules, 不修改当前级别
+func parseCfgLevels(logCfg LogCfg) (cfgLevels, error) {
+	level := levelString(logCfg.LogConf.Level)
+	if _, err := parseLevel(level); err != nil {
+		return cfgLevels{}, err
+	}
+	modules, err := parseModuleLevels(logCfg.LogConf.Modules)
+	if err != nil {
+		return cfgLevels{}, err
+	}
+	return cfgLevels{level: level, modules: modules}, nil
+}
+
+// apply level已校验, SetLevel不会失败
+func (c cfgLevels) apply() {
+	_ = SetLevel(c.level)
+	NewLogUtils().setLogLevel(c.level)
+	storeModuleLevels(c.modules)
+}
+
+// levelString 未配置时为INFO
+func levelString(l config.LogLevel) string {
+	if l == config.LogLevelNull {
+		return "INFO"
+	}
+	return strings.ToUpper(l.String())
+}
+
+// watchHup 收到SIGHUP时Reload, 整个进程只监听一次
+func watchHup() {
+	hupOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGHUP)
+		go func() {
+			for range ch {
+				if err := Reload(); err != nil {
+					Error("reload log level failed. err:%v", err)
+					continue
+				}
+				levelsReloaded()
+			}
+		}()
+	})
+}
+
+// watchFile ReloadOnChange为true时监听配置文件, Level与Modules变化后立即生效; 重新Init时按新的路径与开关替换
+func watchFile(path string, enable bool) {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	if cfgWatcher != nil {
+		if enable && cfgWatcher.Path() == path {
+			return
+		}
+		cfgWatcher.Close()
+		cfgWatcher = nil
+	}
+	if !enable {
+		return
+	}
+	w, err := config.Watch(path, &LogCfg{}, onLogCfgChange, func(err error) {
+		Error("watch log cfg error, keep old. cfg:%s err:%v", path, err)
+	})
+	if err != nil {
+		Error("watch log cfg failed. cfg:%s err:%v", path, err)
+		return
+	}
+	cfgWatcher = w
+}
+
+// onLogCfgChange 只更新Level与Modules, 其他字段需要重新Init
+func onLogCfgChange(old, new *LogCfg) {
+	if old.LogConf.Level == new.LogConf.Level && reflect.DeepEqual(old.LogConf.Modules, new.LogConf.Modules) {
+		return
+	}
+	initMu.Lock()
+	err := applyLevels(*new)
+	initMu.Unlock()
+	if err != nil {
+		Error("reload log level failed. err:%v", err)
+		return
+	}
+	levelsReloaded()
+}
+
+func levelsReloaded() {
+	names := make([]string, 0)
+	for name, level := range ModuleLevels() {
+		names = append(names, name+"="+level)
+	}
+	sort.Strings(names)
+	Warn("log level reloaded. level:%s modules:%v", GetLevel(), names)
+}
@@ -0,0 +1,209 @@
+package fllog
+
+import (
+	"testing"
+
+	"github.com/xiaolongdeng1990/forlife/MSF/config"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var allLevels = []struct {
+	name string
+	conf config.LogLevel
+	zap  zapcore.Level
+}{
+	{"TRACE", config.LogLevelTrace, TraceLevel},
+	{"DEBUG", config.LogLevelDebug, zapcore.DebugLevel},
+	{"INFO", config.LogLevelInfo, zapcore.InfoLevel},
+	{"WARN", config.LogLevelWarning, zapcore.WarnLevel},
+	{"ERROR", config.LogLevelError, zapcore.ErrorLevel},
+	{"FATAL", config.LogLevelFatal, zapcore.FatalLevel},
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    zapcore.Level
+		wantErr bool
+	}{
+		{in: "TRACE", want: TraceLevel},
+		{in: "trace", want: TraceLevel},
+		{in: "Debug", want: zapcore.DebugLevel},
+		{in: " info ", want: zapcore.InfoLevel},
+		{in: "WARN", want: zapcore.WarnLevel},
+		{in: "warning", want: zapcore.WarnLevel},
+		{in: "ERROR", want: zapcore.ErrorLevel},
+		{in: "fatal", want: zapcore.FatalLevel},
+		{in: "", wantErr: true},
+		{in: "loud", wantErr: true},
+		{in: "PANIC", wantErr: true},
+		{in: "INFO2", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLevel(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLevel(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestZapLevel(t *testing.T) {
+	for _, tt := range allLevels {
+		got, err := zapLevel(tt.conf)
+		if err != nil || got != tt.zap {
+			t.Errorf("zapLevel(%v) = %v, %v, want %v", tt.conf, got, err, tt.zap)
+		}
+		if name := levelName(got); name != tt.conf.String() {
+			t.Errorf("levelName(%v) = %q, want %q", got, name, tt.conf.String())
+		}
+	}
+	for _, l := range []config.LogLevel{config.LogLevelNull, 7, 255} {
+		if _, err := zapLevel(l); err == nil {
+			t.Errorf("zapLevel(%d) want error", l)
+		}
+	}
+}
+
+func TestLogLevelUnmarshalText(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    config.LogLevel
+		wantErr bool
+	}{
+		{in: "TRACE", want: config.LogLevelTrace},
+		{in: "debug", want: config.LogLevelDebug},
+		{in: "Info", want: config.LogLevelInfo},
+		{in: "WARN", want: config.LogLevelWarning},
+		{in: "Warning", want: config.LogLevelWarning},
+		{in: "error", want: config.LogLevelError},
+		{in: " FATAL\n", want: config.LogLevelFatal},
+		{in: "", wantErr: true},
+		{in: "verbose", wantErr: true},
+		{in: "3", wantErr: true},
+	}
+	for _, tt := range tests {
+		var l config.LogLevel
+		err := l.UnmarshalText([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalText(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			if l != config.LogLevelNull {
+				t.Errorf("UnmarshalText(%q) changed level to %v on error", tt.in, l)
+			}
+			continue
+		}
+		if l != tt.want {
+			t.Errorf("UnmarshalText(%q) = %v, want %v", tt.in, l, tt.want)
+		}
+		text, _ := l.MarshalText()
+		var back config.LogLevel
+		if err := back.UnmarshalText(text); err != nil || back != l {
+			t.Errorf("MarshalText round trip of %v = %q, %v", l, text, err)
+		}
+	}
+}
+
+func TestSetLevelAllow(t *testing.T) {
+	defer SetLevel(GetLevel())
+	for _, set := range allLevels {
+		if err := SetLevel(set.name); err != nil {
+			t.Fatalf("SetLevel(%s): %v", set.name, err)
+		}
+		if got := GetLevel(); got != set.name {
+			t.Errorf("GetLevel() = %s after SetLevel(%s)", got, set.name)
+		}
+		for _, l := range allLevels {
+			if got, want := Allow(l.name), l.zap >= set.zap; got != want {
+				t.Errorf("level %s: Allow(%s) = %v, want %v", set.name, l.name, got, want)
+			}
+		}
+	}
+
+	if err := SetLevel("WARN"); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{"", "loud", "PANIC"} {
+		if err := SetLevel(bad); err == nil {
+			t.Errorf("SetLevel(%q) want error", bad)
+		}
+		if Allow(bad) {
+			t.Errorf("Allow(%q) = true for invalid level", bad)
+		}
+	}
+	if got := GetLevel(); got != "WARN" {
+		t.Errorf("invalid SetLevel changed level to %s", got)
+	}
+}
+
+func TestLevelCore(t *testing.T) {
+	lv := newLevels()
+	lv.global.SetLevel(zapcore.InfoLevel)
+	lv.modules = map[string]zapcore.Level{
+		"client":       zapcore.DebugLevel,
+		"client.retry": zapcore.ErrorLevel,
+		"db":           TraceLevel,
+	}
+	inner, _ := observer.New(TraceLevel)
+	core := &levelCore{Core: inner, levels: lv}
+
+	tests := []struct {
+		logger string
+		min    zapcore.Level // 该logger能输出的最低级别
+	}{
+		{"", zapcore.InfoLevel},
+		{"server", zapcore.InfoLevel},
+		{"client", zapcore.DebugLevel},
+		{"client.pool", zapcore.DebugLevel}, // 继承client
+		{"client.retry", zapcore.ErrorLevel},
+		{"client.retry.backoff", zapcore.ErrorLevel},
+		{"clientx", zapcore.InfoLevel}, // 只按"."分级匹配
+		{"db", TraceLevel},
+	}
+	for _, tt := range tests {
+		for _, l := range allLevels {
+			ent := zapcore.Entry{LoggerName: tt.logger, Level: l.zap}
+			got := core.Check(ent, nil) != nil
+			if want := l.zap >= tt.min; got != want {
+				t.Errorf("logger %q level %s: written = %v, want %v", tt.logger, l.name, got, want)
+			}
+		}
+	}
+
+	// 任一模块开启了TRACE, Enabled不能提前过滤掉
+	if !core.Enabled(TraceLevel) {
+		t.Error("Enabled(TRACE) = false with db at TRACE")
+	}
+	lv.modules = map[string]zapcore.Level{}
+	if core.Enabled(zapcore.DebugLevel) {
+		t.Error("Enabled(DEBUG) = true with global INFO and no modules")
+	}
+}
+
+func TestSetModuleLevel(t *testing.T) {
+	defer setModuleLevels(nil)
+	if err := SetModuleLevel("client", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetModuleLevel("client", "loud"); err == nil {
+		t.Error("SetModuleLevel with invalid level want error")
+	}
+	if got := ModuleLevels()["client"]; got != "DEBUG" {
+		t.Errorf("ModuleLevels()[client] = %q, want DEBUG", got)
+	}
+	if err := setModuleLevels(map[string]config.LogLevel{"a": config.LogLevelTrace, "b": 42}); err == nil {
+		t.Error("setModuleLevels with invalid level want error")
+	}
+	if err := SetModuleLevel("client", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ModuleLevels()["client"]; ok {
+		t.Error("SetModuleLevel with empty level did not remove override")
+	}
+}
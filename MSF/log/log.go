@@ -2,75 +2,141 @@ package fllog
 
 import (
 	"fmt"
+	"sync"
+
 	"github.com/xiaolongdeng1990/forlife/MSF/config"
+	"go.uber.org/zap/zapcore"
 )
 
 type LogCfg struct {
 	LogConf struct {
-		Name       string `default:"../log/fllog.log"`
-		Level      string `default:"INFO"`
-		MaxSize    int    `default:"1073741824"`
-		MaxAge     int    `default:"30"` //默认最多保存10个日志文件
-		MaxBackups int    `default:"10"` // 最大保存日志数量
+		Name       string          `default:"../log/fllog.log" validate:"required"`
+		Level      config.LogLevel `default:"INFO" validate:"oneof=TRACE DEBUG INFO WARN ERROR FATAL"`
+		MaxSize    config.LogSize  `default:"1G" validate:"min=1M"`           // 单个文件大小上限, 如"512M"
+		MaxAge     int             `default:"30" validate:"min=0"`            // 历史日志保留天数, 0为不按天数清理
+		MaxBackups int             `default:"10" validate:"min=0"`            // 最大保存日志数量, 0为不按数量清理
+		Compress   bool            `default:"false"`                          // 历史日志是否gzip压缩
+		UTC        bool            `default:"false"`                          // 日志时间与切分文件名用UTC, 默认本地时间
+		Rotate     string          `default:"" validate:"oneof=daily hourly"` // 按时间切分, 为空只按大小切分
+		// 多个输出同时生效, 如标准输出+文件+单独的错误日志; 为空时只输出到Name指定的文件
+		Sinks []SinkCfg
+		// 按模块(Named的logger名)覆盖级别, 如 client = "DEBUG"
+		Modules map[string]config.LogLevel
+		// 查看/修改级别的HTTP地址, 如"127.0.0.1:9101", 为空不开启
+		AdminAddr string `default:"" validate:"hostport"`
+		// 收到SIGHUP时重新读取配置文件中的Level与Modules
+		ReloadOnHup bool `default:"false"`
+		// 配置文件修改后自动更新Level与Modules, 不需要SIGHUP
+		ReloadOnChange bool `default:"false"`
+		// 采样与去重, 防止错误风暴时同一行日志刷满文件
+		Sampling SamplingCfg
+		// 异步写日志, 磁盘或网络变慢时不阻塞业务协程
+		Async AsyncCfg
 	}
 }
 
+// initMu 多个协程同时Init时依次执行, 后执行的配置生效
+var initMu sync.Mutex
+
+// Init 读取配置创建logger, 可重复调用: 重新Init时原子替换logger, 失败则保留原来的
 func Init(cfg string) error {
+	initMu.Lock()
+	defer initMu.Unlock()
 	logCfg := LogCfg{}
 
-	if err := config.ParseConfigWithPath(&logCfg, cfg); err != nil {
+	if _, err := config.Load(&logCfg, cfg); err != nil {
 		fmt.Printf("load logcfg failed. err:%+v cfg:%s", err, cfg)
 		return err
 	}
 	fmt.Printf("cfg:%s logCfg:%+v", cfg, logCfg)
+	sampler, err := newSampler(logCfg.LogConf.Sampling)
+	if err != nil {
+		fmt.Printf("invalid log sampling. err:%+v cfg:%s", err, cfg)
+		return err
+	}
+	// 替换logger之前先校验级别, 失败时整个保留原配置
+	levels, err := parseCfgLevels(logCfg)
+	if err != nil {
+		fmt.Printf("invalid log level. err:%+v cfg:%s", err, cfg)
+		return err
+	}
 	builder := NewLogUtilsBuilder(
-		logCfg.LogConf.Level,
+		levelString(logCfg.LogConf.Level),
 		logCfg.LogConf.Name,
-		logCfg.LogConf.MaxSize,
+		int(logCfg.LogConf.MaxSize.Size()),
 		logCfg.LogConf.MaxAge,
 		logCfg.LogConf.MaxBackups,
 		false,
 		true,
-	)
-	logUtils := NewLogUtils().SetBuilder(builder)
-	err := logUtils.Init()
-	if err != nil {
+	).SetCompress(logCfg.LogConf.Compress).
+		SetLocalTime(!logCfg.LogConf.UTC).
+		SetRotate(logCfg.LogConf.Rotate).
+		SetSinks(logCfg.LogConf.Sinks).
+		SetAsync(logCfg.LogConf.Async)
+	if err := NewLogUtils().initWith(builder); err != nil {
 		fmt.Printf("logUtils init failed. err:%+v", err)
 		return err
 	}
+	setSampler(sampler)
+	levels.apply()
+	adminMu.Lock()
+	cfgPath = cfg
+	adminMu.Unlock()
+	serveAdmin(logCfg.LogConf.AdminAddr)
+	if logCfg.LogConf.ReloadOnHup {
+		watchHup()
+	}
+	watchFile(cfg, logCfg.LogConf.ReloadOnChange)
 	fmt.Printf("log init succ. cfg:%s logCfg:%+v", cfg, logCfg)
 	Debug("log init succ. cfg:%s logCfg:%+v", cfg, logCfg)
 	return nil
 }
 
+func Trace(f string, p ...interface{}) {
+	logf(TraceLevel, f, p)
+}
+
 func Debug(f string, p ...interface{}) {
-	if Allow("DEBUG") {
-		msg := fmt.Sprintf(f, p...)
-		utils := NewLogUtils()
-		utils.getLogsUtils().Debug(msg)
-	}
+	logf(zapcore.DebugLevel, f, p)
 }
 
 func Info(f string, p ...interface{}) {
-	if Allow("INFO") {
-		msg := fmt.Sprintf(f, p...)
-		utils := NewLogUtils()
-		utils.getLogsUtils().Info(msg)
-	}
+	logf(zapcore.InfoLevel, f, p)
 }
 
 func Warn(f string, p ...interface{}) {
-	if Allow("WARN") {
-		msg := fmt.Sprintf(f, p...)
-		utils := NewLogUtils()
-		utils.getLogsUtils().Warn(msg)
-	}
+	logf(zapcore.WarnLevel, f, p)
 }
 
 func Error(f string, p ...interface{}) {
-	if Allow("ERROR") {
-		msg := fmt.Sprintf(f, p...)
-		utils := NewLogUtils()
-		utils.getLogsUtils().Error(msg)
+	logf(zapcore.ErrorLevel, f, p)
+}
+
+// logf 先按格式串采样再格式化, 相同消息按配置去重
+func logf(level zapcore.Level, f string, p []interface{}) {
+	if !NewLogUtils().levels.global.Enabled(level) || !sampled(level, "", f) {
+		return
+	}
+	msg := fmt.Sprintf(f, p...)
+	if deduped(level, "", msg) {
+		return
 	}
+	wrapperLogger().Log(level, msg)
+}
+
+// Fatal 输出后退出进程
+func Fatal(f string, p ...interface{}) {
+	msg := fmt.Sprintf(f, p...)
+	utils := NewLogUtils()
+	utils.getLogsUtils().Fatal(msg)
+}
+
+// Close 写完异步队列中的日志并关闭文件与网络连接, 进程退出前调用
+func Close() error {
+	return NewLogUtils().close()
+}
+
+// Sync 将缓冲中的日志刷到输出, 进程退出前调用
+func Sync() error {
+	return Log().Sync()
 }
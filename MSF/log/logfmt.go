@@ -0,0 +1,247 @@
+package fllog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder 以 key=value 输出, 含空格、引号、等号的值加引号, 复杂类型按JSON编码
+type logfmtEncoder struct {
+	cfg zapcore.EncoderConfig
+	buf *buffer.Buffer // With追加的字段
+}
+
+func newLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{cfg: cfg, buf: logfmtPool.Get()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	c := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get()}
+	c.buf.Write(e.buf.Bytes())
+	return c
+}
+
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	line := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get()}
+	if len(e.cfg.TimeKey) > 0 && e.cfg.EncodeTime != nil {
+		line.addPrimitive(e.cfg.TimeKey, func(pe zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeTime(ent.Time, pe) })
+	}
+	if len(e.cfg.LevelKey) > 0 && e.cfg.EncodeLevel != nil {
+		line.addPrimitive(e.cfg.LevelKey, func(pe zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeLevel(ent.Level, pe) })
+	}
+	if len(e.cfg.NameKey) > 0 && len(ent.LoggerName) > 0 {
+		line.AddString(e.cfg.NameKey, ent.LoggerName)
+	}
+	if len(e.cfg.CallerKey) > 0 && ent.Caller.Defined && e.cfg.EncodeCaller != nil {
+		line.addPrimitive(e.cfg.CallerKey, func(pe zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeCaller(ent.Caller, pe) })
+	}
+	if len(e.cfg.MessageKey) > 0 {
+		line.AddString(e.cfg.MessageKey, ent.Message)
+	}
+	if e.buf.Len() > 0 {
+		line.buf.AppendByte(' ')
+		line.buf.Write(e.buf.Bytes())
+	}
+	for i := range fields {
+		fields[i].AddTo(line)
+	}
+	if len(e.cfg.StacktraceKey) > 0 && len(ent.Stack) > 0 {
+		line.AddString(e.cfg.StacktraceKey, ent.Stack)
+	}
+	lineEnding := e.cfg.LineEnding
+	if len(lineEnding) == 0 {
+		lineEnding = zapcore.DefaultLineEnding
+	}
+	line.buf.AppendString(lineEnding)
+	return line.buf, nil
+}
+
+func (e *logfmtEncoder) addKey(key string) {
+	if e.buf.Len() > 0 {
+		e.buf.AppendByte(' ')
+	}
+	e.buf.AppendString(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key))
+	e.buf.AppendByte('=')
+}
+
+// appendValue 需要时加引号转义
+func (e *logfmtEncoder) appendValue(s string) {
+	if needQuote(s) {
+		e.buf.AppendString(strconv.Quote(s))
+		return
+	}
+	e.buf.AppendString(s)
+}
+
+func needQuote(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// addPrimitive 取自定义time/level/caller编码器的输出, 多个值以空格拼接
+func (e *logfmtEncoder) addPrimitive(key string, encode func(zapcore.PrimitiveArrayEncoder)) {
+	var pe primitiveEncoder
+	encode(&pe)
+	e.addKey(key)
+	e.appendValue(strings.Join(pe, " "))
+}
+
+// addJSON 数组、对象与反射类型按JSON编码
+func (e *logfmtEncoder) addJSON(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.addKey(key)
+	e.appendValue(string(b))
+	return nil
+}
+
+func (e *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, arr); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields[key])
+}
+
+func (e *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := obj.MarshalLogObject(m); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields)
+}
+
+func (e *logfmtEncoder) AddReflected(key string, v interface{}) error {
+	return e.addJSON(key, v)
+}
+
+func (e *logfmtEncoder) AddBinary(key string, v []byte) {
+	e.AddString(key, base64.StdEncoding.EncodeToString(v))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, v []byte) { e.AddString(key, string(v)) }
+
+func (e *logfmtEncoder) AddBool(key string, v bool) {
+	e.addKey(key)
+	e.buf.AppendBool(v)
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, v complex128) {
+	e.addKey(key)
+	e.buf.AppendString(strconv.FormatComplex(v, 'g', -1, 128))
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, v complex64) {
+	e.addKey(key)
+	e.buf.AppendString(strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+
+func (e *logfmtEncoder) AddDuration(key string, v time.Duration) {
+	if e.cfg.EncodeDuration == nil {
+		e.AddInt64(key, int64(v))
+		return
+	}
+	e.addPrimitive(key, func(pe zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeDuration(v, pe) })
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, v float64) {
+	e.addKey(key)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		e.buf.AppendString(strconv.FormatFloat(v, 'g', -1, 64))
+		return
+	}
+	e.buf.AppendFloat(v, 64)
+}
+
+func (e *logfmtEncoder) AddFloat32(key string, v float32) {
+	e.addKey(key)
+	e.buf.AppendFloat(float64(v), 32)
+}
+
+func (e *logfmtEncoder) AddInt(key string, v int)     { e.AddInt64(key, int64(v)) }
+func (e *logfmtEncoder) AddInt32(key string, v int32) { e.AddInt64(key, int64(v)) }
+func (e *logfmtEncoder) AddInt16(key string, v int16) { e.AddInt64(key, int64(v)) }
+func (e *logfmtEncoder) AddInt8(key string, v int8)   { e.AddInt64(key, int64(v)) }
+
+func (e *logfmtEncoder) AddInt64(key string, v int64) {
+	e.addKey(key)
+	e.buf.AppendInt(v)
+}
+
+func (e *logfmtEncoder) AddString(key, v string) {
+	e.addKey(key)
+	e.appendValue(v)
+}
+
+func (e *logfmtEncoder) AddTime(key string, v time.Time) {
+	if e.cfg.EncodeTime == nil {
+		e.AddString(key, v.Format(time.RFC3339Nano))
+		return
+	}
+	e.addPrimitive(key, func(pe zapcore.PrimitiveArrayEncoder) { e.cfg.EncodeTime(v, pe) })
+}
+
+func (e *logfmtEncoder) AddUint(key string, v uint)       { e.AddUint64(key, uint64(v)) }
+func (e *logfmtEncoder) AddUint32(key string, v uint32)   { e.AddUint64(key, uint64(v)) }
+func (e *logfmtEncoder) AddUint16(key string, v uint16)   { e.AddUint64(key, uint64(v)) }
+func (e *logfmtEncoder) AddUint8(key string, v uint8)     { e.AddUint64(key, uint64(v)) }
+func (e *logfmtEncoder) AddUintptr(key string, v uintptr) { e.AddUint64(key, uint64(v)) }
+
+func (e *logfmtEncoder) AddUint64(key string, v uint64) {
+	e.addKey(key)
+	e.buf.AppendUint(v)
+}
+
+// OpenNamespace logfmt没有层级, 之后的key不加前缀
+func (e *logfmtEncoder) OpenNamespace(key string) {}
+
+// primitiveEncoder 收集time/level/caller等编码器输出的值
+type primitiveEncoder []string
+
+func (p *primitiveEncoder) AppendBool(v bool)             { *p = append(*p, strconv.FormatBool(v)) }
+func (p *primitiveEncoder) AppendByteString(v []byte)     { *p = append(*p, string(v)) }
+func (p *primitiveEncoder) AppendComplex128(v complex128) { *p = append(*p, fmt.Sprint(v)) }
+func (p *primitiveEncoder) AppendComplex64(v complex64)   { *p = append(*p, fmt.Sprint(v)) }
+func (p *primitiveEncoder) AppendFloat64(v float64) {
+	*p = append(*p, strconv.FormatFloat(v, 'g', -1, 64))
+}
+func (p *primitiveEncoder) AppendFloat32(v float32) {
+	*p = append(*p, strconv.FormatFloat(float64(v), 'g', -1, 32))
+}
+func (p *primitiveEncoder) AppendInt(v int)         { *p = append(*p, strconv.Itoa(v)) }
+func (p *primitiveEncoder) AppendInt64(v int64)     { *p = append(*p, strconv.FormatInt(v, 10)) }
+func (p *primitiveEncoder) AppendInt32(v int32)     { p.AppendInt64(int64(v)) }
+func (p *primitiveEncoder) AppendInt16(v int16)     { p.AppendInt64(int64(v)) }
+func (p *primitiveEncoder) AppendInt8(v int8)       { p.AppendInt64(int64(v)) }
+func (p *primitiveEncoder) AppendString(v string)   { *p = append(*p, v) }
+func (p *primitiveEncoder) AppendUint(v uint)       { p.AppendUint64(uint64(v)) }
+func (p *primitiveEncoder) AppendUint64(v uint64)   { *p = append(*p, strconv.FormatUint(v, 10)) }
+func (p *primitiveEncoder) AppendUint32(v uint32)   { p.AppendUint64(uint64(v)) }
+func (p *primitiveEncoder) AppendUint16(v uint16)   { p.AppendUint64(uint64(v)) }
+func (p *primitiveEncoder) AppendUint8(v uint8)     { p.AppendUint64(uint64(v)) }
+func (p *primitiveEncoder) AppendUintptr(v uintptr) { p.AppendUint64(uint64(v)) }
@@ -0,0 +1,137 @@
+package fllog
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger 带固定字段的子logger, 由With创建, 每条日志输出为JSON字段而非拼进msg
+type Logger struct {
+	name   string
+	fields []interface{}
+	cache  atomic.Pointer[namedLogger]
+}
+
+// With 创建带kv字段的子logger, 如 fllog.With("module", "order").Info("created", "id", id)
+func With(kv ...interface{}) *Logger {
+	return &Logger{fields: mergeFields(nil, kv)}
+}
+
+// Named 创建名为name的模块logger, 可用SetModuleLevel或LogConf.Modules单独设置级别
+func Named(name string) *Logger {
+	return &Logger{name: name}
+}
+
+// With 在当前字段基础上追加字段, 返回新的子logger
+func (l *Logger) With(kv ...interface{}) *Logger {
+	return &Logger{name: l.name, fields: mergeFields(l.fields, kv)}
+}
+
+// Named 创建子模块logger, 名字以"."连接, 如client.retry
+func (l *Logger) Named(name string) *Logger {
+	if len(l.name) > 0 {
+		name = l.name + "." + name
+	}
+	return &Logger{name: name, fields: l.fields}
+}
+
+func (l *Logger) Trace(msg string, kv ...interface{}) {
+	l.log(context.Background(), TraceLevel, msg, kv)
+}
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(context.Background(), zapcore.DebugLevel, msg, kv)
+}
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(context.Background(), zapcore.InfoLevel, msg, kv)
+}
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(context.Background(), zapcore.WarnLevel, msg, kv)
+}
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(context.Background(), zapcore.ErrorLevel, msg, kv)
+}
+
+// Fatal 输出后退出进程
+func (l *Logger) Fatal(msg string, kv ...interface{}) {
+	l.log(context.Background(), zapcore.FatalLevel, msg, kv)
+}
+
+// TraceCtx 同Trace, 并带上ctx中的trace_id/request_id/service/method等字段
+func (l *Logger) TraceCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, TraceLevel, msg, kv)
+}
+
+func (l *Logger) DebugCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, zapcore.DebugLevel, msg, kv)
+}
+
+func (l *Logger) InfoCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, zapcore.InfoLevel, msg, kv)
+}
+
+func (l *Logger) WarnCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, zapcore.WarnLevel, msg, kv)
+}
+
+func (l *Logger) ErrorCtx(ctx context.Context, msg string, kv ...interface{}) {
+	l.log(ctx, zapcore.ErrorLevel, msg, kv)
+}
+
+// 字段顺序: ctx字段 -> logger字段 -> 本条日志的kv, 同名时后者覆盖
+func (l *Logger) log(ctx context.Context, level zapcore.Level, msg string, kv []interface{}) {
+	// 级别由zap core过滤, 这里只在需要采样时提前判断, 避免未输出的日志占用计数
+	if current.Load() != nil {
+		if !NewLogUtils().levels.enabled(l.name, level) || !sampled(level, l.name, msg) || deduped(level, l.name, msg) {
+			return
+		}
+	}
+	fields := mergeFields(mergeFields(FieldsFromContext(ctx), l.fields), kv)
+	l.sugared().Logw(level, msg, fields...)
+}
+
+// namedLogger base为创建时的wrapperLogger, 重新Init后不同, 需要重建
+type namedLogger struct {
+	base *zap.Logger
+	*zap.SugaredLogger
+}
+
+// sugared 缓存带模块名的logger, 不在每条日志上克隆
+func (l *Logger) sugared() *zap.SugaredLogger {
+	base := wrapperLogger()
+	if c := l.cache.Load(); c != nil && c.base == base {
+		return c.SugaredLogger
+	}
+	logger := base
+	if len(l.name) > 0 {
+		logger = logger.Named(l.name)
+	}
+	c := &namedLogger{base: base, SugaredLogger: logger.Sugar()}
+	l.cache.Store(c)
+	return c.SugaredLogger
+}
+
+var root = &Logger{}
+
+// TraceCtx 结构化日志, kv为交替的key/value, ctx中的字段自动带上
+func TraceCtx(ctx context.Context, msg string, kv ...interface{}) {
+	root.log(ctx, TraceLevel, msg, kv)
+}
+
+func DebugCtx(ctx context.Context, msg string, kv ...interface{}) {
+	root.log(ctx, zapcore.DebugLevel, msg, kv)
+}
+
+func InfoCtx(ctx context.Context, msg string, kv ...interface{}) {
+	root.log(ctx, zapcore.InfoLevel, msg, kv)
+}
+
+func WarnCtx(ctx context.Context, msg string, kv ...interface{}) {
+	root.log(ctx, zapcore.WarnLevel, msg, kv)
+}
+
+func ErrorCtx(ctx context.Context, msg string, kv ...interface{}) {
+	root.log(ctx, zapcore.ErrorLevel, msg, kv)
+}
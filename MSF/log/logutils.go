@@ -1,34 +1,28 @@
 package fllog
 
 import (
-	"encoding/json"
+	"io"
 	"log"
-	"os"
 	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/fatih/color"
-	orderedmap "github.com/wk8/go-ordered-map"
 	"go.uber.org/zap"
-	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
-	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var LogLevelMap = map[string]int{
-	"debug": 1,
-	"info":  2,
-	"warn":  3,
-	"error": 4,
-}
-
 type MyLogUtilsBuilder struct {
 	logLevel    string
 	logFileName string
-	maxSize     int
-	maxAge      int
+	maxSize     int // 单个文件大小上限, 字节
+	maxAge      int // 历史文件保留天数
 	maxBackups  int
+	compress    bool
+	localTime   bool
+	rotate      string // 按时间切分: daily/hourly, 为空只按大小切分
+	sinks       []SinkCfg
+	async       AsyncCfg
 	status      bool
 	line        bool
 }
@@ -61,6 +55,7 @@ func NewLogUtilsBuilder(logLevel string, logFileName string, maxSize int, maxAge
 		maxSize:     maxSize,
 		maxAge:      maxAge,
 		maxBackups:  maxBackups,
+		localTime:   true,
 		status:      status,
 		line:        line,
 	}
@@ -97,215 +92,198 @@ func (m *MyLogUtilsBuilder) SetMaxBackups(MaxBackups int) BuilderInterface {
 	return m
 }
 
+func (m *MyLogUtilsBuilder) GetMaxSize() int {
+	return m.maxSize
+}
+
+func (m *MyLogUtilsBuilder) GetMaxAge() int {
+	return m.maxAge
+}
+
+func (m *MyLogUtilsBuilder) GetMaxBackups() int {
+	return m.maxBackups
+}
+
+func (m *MyLogUtilsBuilder) SetCompress(compress bool) BuilderInterface {
+	m.compress = compress
+	return m
+}
+
+func (m *MyLogUtilsBuilder) GetCompress() bool {
+	return m.compress
+}
+
+func (m *MyLogUtilsBuilder) SetLocalTime(localTime bool) BuilderInterface {
+	m.localTime = localTime
+	return m
+}
+
+func (m *MyLogUtilsBuilder) GetLocalTime() bool {
+	return m.localTime
+}
+
+func (m *MyLogUtilsBuilder) SetRotate(rotate string) BuilderInterface {
+	m.rotate = rotate
+	return m
+}
+
+func (m *MyLogUtilsBuilder) GetRotate() string {
+	return m.rotate
+}
+
+func (m *MyLogUtilsBuilder) SetSinks(sinks []SinkCfg) BuilderInterface {
+	m.sinks = sinks
+	return m
+}
+
+func (m *MyLogUtilsBuilder) GetSinks() []SinkCfg {
+	return m.sinks
+}
+
+func (m *MyLogUtilsBuilder) SetAsync(async AsyncCfg) BuilderInterface {
+	m.async = async
+	return m
+}
+
+func (m *MyLogUtilsBuilder) GetAsync() AsyncCfg {
+	return m.async
+}
+
 type myLogUtils struct {
+	mu            sync.Mutex // Init与Close互斥
 	builders      BuilderInterface
-	sugaredLogger *zap.SugaredLogger
+	sugaredLogger atomic.Pointer[zap.SugaredLogger]
+	skipLogger    atomic.Pointer[zap.Logger] // 跳过fllog封装两层, 与sugaredLogger同时替换
+	closers       []io.Closer
+	levels        *levels
 }
 
 var once sync.Once
 var instance *myLogUtils
 
+// NewLogUtils 首次调用时创建输出到标准错误的INFO级别logger, fllog.Init之前也可以直接打日志
 func NewLogUtils() *myLogUtils {
 	once.Do(func() {
-		instance = &myLogUtils{}
+		instance = &myLogUtils{levels: newLevels(), builders: defaultBuilder()}
+		if err := instance.Init(); err != nil {
+			log.Println(err)
+		}
 	})
 	return instance
 }
 
+// defaultBuilder Init之前的配置: 标准错误, INFO, 控制台格式
+func defaultBuilder() BuilderInterface {
+	return NewLogUtilsBuilder("INFO", "", 0, 0, 0, false, true).
+		SetSinks([]SinkCfg{{Type: SinkStderr}})
+}
+
+// SetBuilder 替换配置, 下次Init时生效
 func (ms *myLogUtils) SetBuilder(builder BuilderInterface) *myLogUtils {
+	ms.mu.Lock()
 	ms.builders = builder
+	ms.mu.Unlock()
 	return ms
 }
 
+// initWith 用builder重新Init, 失败时保留原来的builder与logger
+func (ms *myLogUtils) initWith(builder BuilderInterface) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	old := ms.builders
+	ms.builders = builder
+	if err := ms.init(); err != nil {
+		ms.builders = old
+		return err
+	}
+	return nil
+}
+
+// setLogLevel 记录运行中修改后的级别, 重新Init时沿用
+func (ms *myLogUtils) setLogLevel(level string) {
+	ms.mu.Lock()
+	ms.builders.SetLogLevel(level)
+	ms.mu.Unlock()
+}
+
 func (ms *myLogUtils) getLogsUtils() *zap.SugaredLogger {
-	return ms.sugaredLogger
+	return ms.sugaredLogger.Load()
 }
 
+// wrapperLogger line记录调用fllog.Info或Logger.Info的业务代码, Init时创建一次
+func wrapperLogger() *zap.Logger {
+	return NewLogUtils().skipLogger.Load()
+}
+
+// Log 返回当前的logger, 重新Init后返回新的logger
 func Log() *zap.SugaredLogger {
-	utils := NewLogUtils()
-	err := utils.Init()
-	if err != nil {
-		log.Fatalln(err)
-		return nil
-	}
-	return utils.getLogsUtils()
+	return NewLogUtils().getLogsUtils()
 }
 
+// Allow level级别的日志当前是否会输出
 func Allow(level string) bool {
-	utils := NewLogUtils()
-	if utils == nil {
+	l, err := parseLevel(level)
+	if err != nil {
 		return false
 	}
-	logLevel := utils.builders.GetLogLevel()
-	realLevel := LogLevelMap[strings.ToLower(logLevel)]
-	checkLevel := LogLevelMap[strings.ToLower(level)]
-	return realLevel >= checkLevel
+	return NewLogUtils().levels.global.Enabled(l)
 }
 
-type CustomEncoder struct {
-	zapcore.Encoder
+// Init 按builder创建各输出, 成功后原子替换logger并关闭上一次打开的文件与连接
+func (ms *myLogUtils) Init() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.init()
 }
 
-func (ce *CustomEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
-	// 先将原始编码器的 EncodeEntry 方法调用结果存储在 buf 中
-	buf, err := ce.Encoder.EncodeEntry(ent, fields)
+// init 调用方持有mu, builders只在mu下读写
+func (ms *myLogUtils) init() error {
+	// 日志级别, 无法识别时为INFO; 运行中可用SetLevel修改
+	level, err := parseLevel(ms.builders.GetLogLevel())
 	if err != nil {
-		return nil, err
-	}
-
-	// 将 buf 转换为 JSON 对象
-	var jsonObj map[string]interface{}
-	if err := json.Unmarshal(buf.Bytes(), &jsonObj); err != nil {
-		return nil, err
+		level = zapcore.InfoLevel
 	}
 
-	// 删除原始的 caller、time 和 level 字段
-	callerValue := jsonObj["caller"]
-	timeValue := jsonObj["time"]
-	levelValue := jsonObj["level"]
-	delete(jsonObj, "caller")
-	delete(jsonObj, "time")
-	delete(jsonObj, "level")
-
-	// 按照期望的顺序重新插入 caller、time 和 level 字段
-	orderedJsonObj := orderedmap.New()
-	orderedJsonObj.Set("caller", callerValue)
-	orderedJsonObj.Set("time", timeValue)
-	orderedJsonObj.Set("level", levelValue)
-	for k, v := range jsonObj {
-		orderedJsonObj.Set(k, v)
+	sinks := ms.builders.GetSinks()
+	if len(sinks) == 0 {
+		sinks = []SinkCfg{ms.defaultSink()}
 	}
-
-	// 将重新排序后的 JSON 对象转换回 buffer.Buffer
-	orderedJsonBytes, err := json.Marshal(orderedJsonObj)
-	if err != nil {
-		return nil, err
-	}
-	buf.Reset()
-	buf.Write(orderedJsonBytes)
-
-	return buf, nil
-}
-
-func (ms *myLogUtils) Init() error {
-	var showLine string
-	// 日志级别
-	//logLevel := "DEBUG"
-	atomicLevel := zap.NewAtomicLevel()
-	switch ms.builders.GetLogLevel() {
-	case "DEBUG":
-		atomicLevel.SetLevel(zapcore.DebugLevel)
-	case "INFO":
-		atomicLevel.SetLevel(zapcore.InfoLevel)
-	case "WARN":
-		atomicLevel.SetLevel(zapcore.WarnLevel)
-	case "ERROR":
-		atomicLevel.SetLevel(zapcore.ErrorLevel)
-	case "DPANIC":
-		atomicLevel.SetLevel(zapcore.DPanicLevel)
-	case "PANIC":
-		atomicLevel.SetLevel(zapcore.PanicLevel)
-	case "FATAL":
-		atomicLevel.SetLevel(zapcore.FatalLevel)
-	}
-
-	if ms.builders.GetConsole() {
-		if ms.builders.GetLine() {
-			showLine = "line"
-		} else {
-			showLine = ""
-		}
-
-		// 自定义日志级别颜色
-		colors := map[zapcore.Level]color.Attribute{
-			zapcore.DebugLevel:  color.FgBlue,
-			zapcore.InfoLevel:   color.FgGreen,
-			zapcore.WarnLevel:   color.FgYellow,
-			zapcore.ErrorLevel:  color.FgRed,
-			zapcore.DPanicLevel: color.FgMagenta,
-			zapcore.PanicLevel:  color.FgMagenta,
-			zapcore.FatalLevel:  color.FgMagenta,
-		}
-
-		encoderConfig := zapcore.EncoderConfig{
-			TimeKey:          "time",
-			LevelKey:         "level",
-			MessageKey:       "msg",
-			CallerKey:        showLine,
-			LineEnding:       zapcore.DefaultLineEnding,
-			EncodeLevel:      coloredLevelEncoder(colors),
-			EncodeTime:       zapcore.TimeEncoderOfLayout("[2006-01-02 15:04:05]"),
-			EncodeDuration:   zapcore.SecondsDurationEncoder,
-			EncodeCaller:     zapcore.FullCallerEncoder, //.ShortCallerEncoder,
-			EncodeName:       zapcore.FullNameEncoder,
-			ConsoleSeparator: "",
-		}
-
-		zapCore := zapcore.NewCore(
-			zapcore.NewConsoleEncoder(encoderConfig),
-			zapcore.AddSync(os.Stdout),
-			atomicLevel,
-		)
-		logutils := zap.New(zapCore, zap.AddCaller()).Sugar()
-		ms.sugaredLogger = logutils
-	} else {
-
-		// 自定义时间输出格式
-		customTimeEncoder := func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendString("[" + t.Format("2006-01-02 15:04:05") + "]")
+	cores := make([]zapcore.Core, 0, len(sinks))
+	closers := make([]io.Closer, 0, len(sinks))
+	for _, sink := range sinks {
+		core, closer, err := ms.newSinkCore(sink)
+		if err != nil {
+			closeAll(closers)
+			return err
 		}
-
-		// 自定义文件：行号输出项
-		customCallerEncoder := func(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendString("[" + caller.TrimmedPath() + "]")
-		}
-
-		encoderConfig := zapcore.EncoderConfig{
-			TimeKey:    "time",
-			CallerKey:  "line",
-			LevelKey:   "level",
-			NameKey:    "name",
-			MessageKey: "msg",
-			// FunctionKey:   "func",
-			// StacktraceKey: "stacktrace",
-
-			LineEnding:  zapcore.DefaultLineEnding,
-			EncodeLevel: zapcore.LowercaseLevelEncoder,
-			// EncodeTime:     zapcore.TimeEncoderOfLayout("2006-01-02 15:04:05"),
-			EncodeTime:     customTimeEncoder,
-			EncodeDuration: zapcore.SecondsDurationEncoder,
-			// EncodeCaller:   zapcore.FullCallerEncoder,
-			EncodeCaller: customCallerEncoder,
-			EncodeName:   zapcore.FullNameEncoder,
+		cores = append(cores, core)
+		if closer != nil {
+			closers = append(closers, closer)
 		}
-		// 日志轮转
-		writer := &lumberjack.Logger{
-			// 日志名称
-			Filename: ms.builders.GetLogFileName(),
-			// 日志大小限制，单位MB
-			MaxSize: 50,
-			// 历史日志文件保留天数
-			MaxAge: 30,
-			// 最大保留历史日志数量,其实就是备份数量
-			MaxBackups: 10,
-			// 本地时区
-			LocalTime: true,
-			// 历史日志文件压缩标识
-			Compress: false,
-		}
-
-		zapCore := zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig),
-			zapcore.AddSync(writer),
-			atomicLevel,
-		)
-
-		logutils := zap.New(zapCore, zap.AddCaller()).Sugar()
-		ms.sugaredLogger = logutils
 	}
+
+	ms.levels.global.SetLevel(level)
+	core := &levelCore{Core: zapcore.NewTee(cores...), levels: ms.levels}
+	logger := zap.New(core, zap.AddCaller())
+	ms.skipLogger.Store(logger.WithOptions(zap.AddCallerSkip(2)))
+	ms.sugaredLogger.Store(logger.Sugar())
+	old := ms.closers
+	ms.closers = closers
+	closeAll(old)
 	return nil
 }
 
+// close 写完异步队列并关闭文件与连接
+func (ms *myLogUtils) close() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	err := ms.getLogsUtils().Sync()
+	closers := ms.closers
+	ms.closers = nil
+	closeAll(closers)
+	return err
+}
+
 // 自定义带颜色的日志级别编码函数
 func coloredLevelEncoder(colors map[zapcore.Level]color.Attribute) zapcore.LevelEncoder {
 	return func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
@@ -314,6 +292,6 @@ func coloredLevelEncoder(colors map[zapcore.Level]color.Attribute) zapcore.Level
 			c = color.Reset // 默认为重置颜色
 		}
 
-		enc.AppendString(color.New(c).Sprint(l.String()))
+		enc.AppendString(color.New(c).Sprint(strings.ToUpper(levelName(l))))
 	}
 }
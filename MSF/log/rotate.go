@@ -0,0 +1,72 @@
+package fllog
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 按时间切分日志的周期
+const (
+	RotateNone   = ""
+	RotateDaily  = "daily"
+	RotateHourly = "hourly"
+)
+
+// sizeInMB lumberjack按MB计, 不足1MB按1MB, 0表示使用lumberjack默认的100MB
+func sizeInMB(size int) int {
+	if size <= 0 {
+		return 0
+	}
+	mb := size / (1024 * 1024)
+	if mb == 0 {
+		mb = 1
+	}
+	return mb
+}
+
+// rotateWriter 在lumberjack按大小切分的基础上, 跨天/跨小时后写第一条日志时切分
+type rotateWriter struct {
+	*lumberjack.Logger
+	rotate string
+
+	mu     sync.Mutex
+	period time.Time
+}
+
+func newRotateWriter(l *lumberjack.Logger, rotate string) *rotateWriter {
+	return &rotateWriter{Logger: l, rotate: strings.ToLower(rotate)}
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	if len(w.rotate) > 0 {
+		w.mu.Lock()
+		now := w.truncate(time.Now())
+		if w.period.IsZero() {
+			// 进程重启时沿用已有文件所在的周期, 跨周期的旧文件先切走
+			w.period = now
+			if info, err := os.Stat(w.Filename); err == nil {
+				w.period = w.truncate(info.ModTime())
+			}
+		}
+		if !now.Equal(w.period) {
+			w.period = now
+			_ = w.Logger.Rotate()
+		}
+		w.mu.Unlock()
+	}
+	return w.Logger.Write(p)
+}
+
+func (w *rotateWriter) truncate(t time.Time) time.Time {
+	if !w.LocalTime {
+		t = t.UTC()
+	}
+	if w.rotate == RotateHourly {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,207 @@
+package fllog
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultSampleTick = time.Second
+
+// SampleRule 每个周期内同级别同模板先输出Initial条, 之后每Thereafter条输出一条
+type SampleRule struct {
+	Initial    int `default:"0" validate:"min=0"` // 为0不采样
+	Thereafter int `default:"0" validate:"min=0"` // 为0时超过Initial的全部丢弃
+}
+
+// SamplingCfg 日志采样与去重, 只作用于fllog的printf与结构化接口, 直接使用Log()不受影响
+type SamplingCfg struct {
+	Initial    int `default:"0" validate:"min=0"`
+	Thereafter int `default:"0" validate:"min=0"`
+	// 按级别覆盖Initial/Thereafter, 如 [LogConf.Sampling.Levels.ERROR]
+	Levels map[string]SampleRule
+	// 周期内完全相同的消息只输出一次, 周期结束时补一条带重复次数(repeated)的日志
+	Dedup bool          `default:"false"`
+	Tick  time.Duration `default:"1s" validate:"min=1ms"` // 采样与去重的统计周期
+}
+
+type sampleKey struct {
+	level zapcore.Level
+	name  string
+	tmpl  string
+}
+
+// sampler 按级别+logger名+消息模板计数, 每个周期清零
+type sampler struct {
+	rules map[zapcore.Level]SampleRule
+	def   SampleRule
+	dedup bool
+	tick  time.Duration
+
+	mu      sync.Mutex
+	window  time.Time
+	counts  map[sampleKey]int
+	repeats map[sampleKey]int
+	stop    chan struct{}
+}
+
+var (
+	// current 当前生效的采样配置, 为nil不采样
+	current atomic.Pointer[sampler]
+	dropped uint64
+)
+
+func newSampler(cfg SamplingCfg) (*sampler, error) {
+	s := &sampler{
+		rules:   make(map[zapcore.Level]SampleRule, len(cfg.Levels)),
+		def:     SampleRule{Initial: cfg.Initial, Thereafter: cfg.Thereafter},
+		dedup:   cfg.Dedup,
+		tick:    cfg.Tick,
+		counts:  make(map[sampleKey]int),
+		repeats: make(map[sampleKey]int),
+		stop:    make(chan struct{}),
+	}
+	if s.tick <= 0 {
+		s.tick = defaultSampleTick
+	}
+	for name, rule := range cfg.Levels {
+		l, err := parseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("sampling: %w", err)
+		}
+		s.rules[l] = rule
+	}
+	return s, nil
+}
+
+// enabled 是否配置了采样或去重
+func (s *sampler) enabled() bool {
+	if s.dedup || s.def.Initial > 0 {
+		return true
+	}
+	for _, rule := range s.rules {
+		if rule.Initial > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// allow 按模板采样, 格式化之前调用
+func (s *sampler) allow(level zapcore.Level, name, tmpl string) bool {
+	rule, ok := s.rules[level]
+	if !ok {
+		rule = s.def
+	}
+	if rule.Initial <= 0 {
+		return true
+	}
+	key := sampleKey{level: level, name: name, tmpl: tmpl}
+	s.mu.Lock()
+	s.roll(time.Now())
+	n := s.counts[key] + 1
+	s.counts[key] = n
+	s.mu.Unlock()
+	if n <= rule.Initial || (rule.Thereafter > 0 && (n-rule.Initial)%rule.Thereafter == 0) {
+		return true
+	}
+	atomic.AddUint64(&dropped, 1)
+	return false
+}
+
+// duplicate 本周期内已输出过相同消息时计数并返回true
+func (s *sampler) duplicate(level zapcore.Level, name, msg string) bool {
+	if !s.dedup {
+		return false
+	}
+	key := sampleKey{level: level, name: name, tmpl: msg}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.repeats[key]
+	if !ok {
+		s.repeats[key] = 0
+		return false
+	}
+	s.repeats[key] = n + 1
+	return true
+}
+
+// roll 进入新周期时清空计数, 调用方持有mu
+func (s *sampler) roll(now time.Time) {
+	if now.Sub(s.window) < s.tick {
+		return
+	}
+	s.window = now
+	if len(s.counts) > 0 {
+		s.counts = make(map[sampleKey]int)
+	}
+}
+
+// run 每个周期输出被合并的重复日志
+func (s *sampler) run() {
+	if !s.dedup {
+		return
+	}
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *sampler) flush() {
+	s.mu.Lock()
+	repeats := s.repeats
+	s.repeats = make(map[sampleKey]int)
+	s.mu.Unlock()
+	for key, n := range repeats {
+		if n == 0 {
+			continue
+		}
+		logger := Log()
+		if len(key.name) > 0 {
+			logger = logger.Named(key.name)
+		}
+		// 汇总行没有业务调用处, 不输出line
+		logger.Desugar().WithOptions(zap.WithCaller(false)).Log(key.level, key.tmpl, zap.Int("repeated", n))
+	}
+}
+
+// setSampler 替换采样器, 未配置采样与去重时关闭; 旧采样器合并的重复日志先输出
+func setSampler(s *sampler) {
+	if !s.enabled() {
+		s = nil
+	} else {
+		go s.run()
+	}
+	if old := current.Swap(s); old != nil {
+		close(old.stop)
+	}
+}
+
+// sampled 采样通过返回true, 未配置采样时总是true
+func sampled(level zapcore.Level, name, tmpl string) bool {
+	s := current.Load()
+	return s == nil || s.allow(level, name, tmpl)
+}
+
+// deduped 去重模式下本周期已输出过相同消息
+func deduped(level zapcore.Level, name, msg string) bool {
+	s := current.Load()
+	return s != nil && s.duplicate(level, name, msg)
+}
+
+// SampledDropped 因采样丢弃的日志条数
+func SampledDropped() uint64 {
+	return atomic.LoadUint64(&dropped)
+}
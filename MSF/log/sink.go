@@ -0,0 +1,296 @@
+package fllog
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/xiaolongdeng1990/forlife/MSF/config"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 输出目的地
+const (
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+	SinkTCP    = "tcp"
+	SinkUDP    = "udp"
+)
+
+// 编码格式
+const (
+	EncoderJSON    = "json"
+	EncoderConsole = "console"
+	EncoderLogfmt  = "logfmt"
+)
+
+// SinkCfg 一个日志输出, 对应toml中的 [[LogConf.Sinks]]
+type SinkCfg struct {
+	Type    string          `default:"file" validate:"oneof=stdout stderr file syslog tcp udp"`
+	Level   config.LogLevel `default:""`                                      // 该输出的最低级别, 为空只受LogConf.Level限制
+	Encoder string          `default:"" validate:"oneof=json console logfmt"` // 为空时stdout/stderr为console, 其余为json
+	// file: 文件路径, 为空用LogConf.Name; 切分参数为0/空时沿用LogConf中的配置
+	Name       string
+	MaxSize    config.LogSize `validate:"min=0"`
+	MaxAge     int            `validate:"min=0"`
+	MaxBackups int            `validate:"min=0"`
+	Compress   *bool          // 不填沿用LogConf.Compress, 填false可单独关闭压缩
+	Rotate     string         `validate:"oneof=daily hourly"`
+	// syslog: unix socket路径, 为空用本机默认; tcp/udp: host:port
+	Addr string
+	Tag  string // syslog的tag, 为空用进程名
+}
+
+// defaultSink 未配置Sinks时的输出, 与之前的行为一致: console为标准输出, 否则为json文件
+func (ms *myLogUtils) defaultSink() SinkCfg {
+	if ms.builders.GetConsole() {
+		return SinkCfg{Type: SinkStdout, Encoder: EncoderConsole}
+	}
+	return SinkCfg{Type: SinkFile, Encoder: EncoderJSON}
+}
+
+// newSinkCore 按配置创建输出及其core, 返回的Closer在重新Init时关闭
+// 全局与模块级别在外层的levelCore过滤, 这里只判断该输出自己的最低级别
+func (ms *myLogUtils) newSinkCore(sink SinkCfg) (zapcore.Core, io.Closer, error) {
+	typ := strings.ToLower(sink.Type)
+	var enabler zapcore.LevelEnabler = TraceLevel
+	if sink.Level != config.LogLevelNull {
+		min, err := zapLevel(sink.Level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sink %s: %w", typ, err)
+		}
+		enabler = min
+	}
+
+	encoderName := strings.ToLower(sink.Encoder)
+	if len(encoderName) == 0 {
+		encoderName = EncoderJSON
+		if typ == SinkStdout || typ == SinkStderr {
+			encoderName = EncoderConsole
+		}
+	}
+	colored := typ == SinkStdout || typ == SinkStderr
+	encoder, err := ms.newEncoder(encoderName, colored)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sink %s: %w", typ, err)
+	}
+
+	var ws zapcore.WriteSyncer
+	var closer io.Closer
+	switch typ {
+	case SinkStdout:
+		ws = zapcore.Lock(os.Stdout)
+	case SinkStderr:
+		ws = zapcore.Lock(os.Stderr)
+	case SinkFile:
+		writer := ms.newFileWriter(sink)
+		ws, closer = zapcore.AddSync(writer), writer
+	case SinkSyslog:
+		return newSyslogCore(sink, encoder, enabler)
+	case SinkTCP, SinkUDP:
+		if len(sink.Addr) == 0 {
+			return nil, nil, fmt.Errorf("sink %s: addr empty", typ)
+		}
+		writer := &netWriter{network: typ, addr: sink.Addr}
+		ws, closer = zapcore.AddSync(writer), writer
+	default:
+		return nil, nil, fmt.Errorf("not support log sink %v", sink.Type)
+	}
+	// 异步模式下由后台协程写, 调用方不再等磁盘与网络
+	if async := ms.builders.GetAsync(); async.Enable {
+		writer := newAsyncWriter(ws, closer, async)
+		ws, closer = writer, writer
+	}
+	return zapcore.NewCore(encoder, ws, enabler), closer, nil
+}
+
+func (ms *myLogUtils) newEncoder(name string, colored bool) (zapcore.Encoder, error) {
+	switch name {
+	case EncoderJSON:
+		return newJSONEncoder(ms.fileEncoderConfig()), nil
+	case EncoderConsole:
+		return zapcore.NewConsoleEncoder(ms.consoleEncoderConfig(colored)), nil
+	case EncoderLogfmt:
+		return newLogfmtEncoder(ms.fileEncoderConfig()), nil
+	}
+	return nil, fmt.Errorf("not support log encoder %v", name)
+}
+
+// newFileWriter 按大小/时间切分的文件, 未配置的参数沿用LogConf
+func (ms *myLogUtils) newFileWriter(sink SinkCfg) *rotateWriter {
+	name, maxSize, maxAge, maxBackups := sink.Name, int(sink.MaxSize), sink.MaxAge, sink.MaxBackups
+	compress, rotate := ms.builders.GetCompress(), sink.Rotate
+	if sink.Compress != nil {
+		compress = *sink.Compress
+	}
+	if len(name) == 0 {
+		name = ms.builders.GetLogFileName()
+	}
+	if maxSize <= 0 {
+		maxSize = ms.builders.GetMaxSize()
+	}
+	if maxAge <= 0 {
+		maxAge = ms.builders.GetMaxAge()
+	}
+	if maxBackups <= 0 {
+		maxBackups = ms.builders.GetMaxBackups()
+	}
+	if len(rotate) == 0 {
+		rotate = ms.builders.GetRotate()
+	}
+	// 日志轮转
+	return newRotateWriter(&lumberjack.Logger{
+		// 日志名称
+		Filename: name,
+		// 日志大小限制，单位MB
+		MaxSize: sizeInMB(maxSize),
+		// 历史日志文件保留天数
+		MaxAge: maxAge,
+		// 最大保留历史日志数量,其实就是备份数量
+		MaxBackups: maxBackups,
+		// 本地时区
+		LocalTime: ms.builders.GetLocalTime(),
+		// 历史日志文件压缩标识
+		Compress: compress,
+	}, rotate)
+}
+
+// consoleEncoderConfig 终端输出, 标准输出/错误时级别带颜色
+func (ms *myLogUtils) consoleEncoderConfig(colored bool) zapcore.EncoderConfig {
+	var showLine string
+	if ms.builders.GetLine() {
+		showLine = "line"
+	}
+
+	// 自定义日志级别颜色
+	colors := map[zapcore.Level]color.Attribute{
+		TraceLevel:          color.FgCyan,
+		zapcore.DebugLevel:  color.FgBlue,
+		zapcore.InfoLevel:   color.FgGreen,
+		zapcore.WarnLevel:   color.FgYellow,
+		zapcore.ErrorLevel:  color.FgRed,
+		zapcore.DPanicLevel: color.FgMagenta,
+		zapcore.PanicLevel:  color.FgMagenta,
+		zapcore.FatalLevel:  color.FgMagenta,
+	}
+	encodeLevel := capitalLevelEncoder
+	if colored {
+		encodeLevel = coloredLevelEncoder(colors)
+	}
+
+	return zapcore.EncoderConfig{
+		TimeKey:          "time",
+		LevelKey:         "level",
+		MessageKey:       "msg",
+		CallerKey:        showLine,
+		LineEnding:       zapcore.DefaultLineEnding,
+		EncodeLevel:      encodeLevel,
+		EncodeTime:       ms.timeEncoder(),
+		EncodeDuration:   zapcore.SecondsDurationEncoder,
+		EncodeCaller:     zapcore.FullCallerEncoder, //.ShortCallerEncoder,
+		EncodeName:       zapcore.FullNameEncoder,
+		ConsoleSeparator: "",
+	}
+}
+
+// fileEncoderConfig json/logfmt输出
+func (ms *myLogUtils) fileEncoderConfig() zapcore.EncoderConfig {
+	// 自定义文件：行号输出项
+	customCallerEncoder := func(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString("[" + caller.TrimmedPath() + "]")
+	}
+
+	return zapcore.EncoderConfig{
+		TimeKey:    "time",
+		CallerKey:  "line",
+		LevelKey:   "level",
+		NameKey:    "name",
+		MessageKey: "msg",
+		// FunctionKey:   "func",
+		// StacktraceKey: "stacktrace",
+
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    lowercaseLevelEncoder,
+		EncodeTime:     ms.timeEncoder(),
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   customCallerEncoder,
+		EncodeName:     zapcore.FullNameEncoder,
+	}
+}
+
+// lowercaseLevelEncoder 同zapcore.LowercaseLevelEncoder, 支持TRACE
+func lowercaseLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(levelName(l))
+}
+
+func capitalLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(strings.ToUpper(levelName(l)))
+}
+
+// timeEncoder 自定义时间输出格式, 按配置使用本地时间或UTC
+func (ms *myLogUtils) timeEncoder() zapcore.TimeEncoder {
+	localTime := ms.builders.GetLocalTime()
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		if !localTime {
+			t = t.UTC()
+		}
+		enc.AppendString("[" + t.Format("2006-01-02 15:04:05") + "]")
+	}
+}
+
+// netWriter tcp/udp输出, 每条日志一次Write, 失败时重连一次, 仍失败则丢弃该条
+type netWriter struct {
+	network string
+	addr    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+const netDialTimeout = time.Second
+
+func (w *netWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		if w.conn == nil {
+			if w.conn, err = net.DialTimeout(w.network, w.addr, netDialTimeout); err != nil {
+				w.conn = nil
+				return 0, err
+			}
+		}
+		if _, err = w.conn.Write(p); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return 0, err
+}
+
+func (w *netWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,55 @@
+package fllog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xiaolongdeng1990/forlife/MSF/config"
+)
+
+func TestFileSinkCompress(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		global bool
+		sink   *bool
+		want   bool
+	}{
+		{global: false, sink: nil, want: false},
+		{global: true, sink: nil, want: true},
+		{global: false, sink: &on, want: true},
+		{global: true, sink: &off, want: false},
+	}
+	for _, tt := range tests {
+		ms := &myLogUtils{builders: NewLogUtilsBuilder("INFO", "a.log", 0, 0, 0, false, true).SetCompress(tt.global)}
+		w := ms.newFileWriter(SinkCfg{Type: SinkFile, Compress: tt.sink})
+		if w.Compress != tt.want {
+			t.Errorf("global %v sink %v: Compress = %v, want %v", tt.global, tt.sink, w.Compress, tt.want)
+		}
+	}
+}
+
+func TestSinkCompressConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.toml")
+	data := `[LogConf]
+Compress = true
+[[LogConf.Sinks]]
+Type = "file"
+Name = "a.log"
+[[LogConf.Sinks]]
+Type = "file"
+Name = "b.log"
+Compress = false
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg LogCfg
+	if _, err := config.Load(&cfg, path); err != nil {
+		t.Fatal(err)
+	}
+	sinks := cfg.LogConf.Sinks
+	if len(sinks) != 2 || sinks[0].Compress != nil || sinks[1].Compress == nil || *sinks[1].Compress {
+		t.Fatalf("sinks = %+v", sinks)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build !windows && !plan9
+
+package fllog
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// syslogCore 按日志级别映射syslog优先级
+type syslogCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	w   *syslog.Writer
+}
+
+// newSyslogCore Addr为空时连本机syslog, 否则连指定的unix socket
+func newSyslogCore(sink SinkCfg, enc zapcore.Encoder, enabler zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
+	network := ""
+	if len(sink.Addr) > 0 {
+		network = "unixgram"
+	}
+	w, err := syslog.Dial(network, sink.Addr, syslog.LOG_INFO|syslog.LOG_USER, sink.Tag)
+	if err != nil && network == "unixgram" {
+		w, err = syslog.Dial("unix", sink.Addr, syslog.LOG_INFO|syslog.LOG_USER, sink.Tag)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &syslogCore{LevelEnabler: enabler, enc: enc, w: w}, w, nil
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return &syslogCore{LevelEnabler: c.LevelEnabler, enc: enc, w: c.w}
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(buf.String(), "\n")
+	buf.Free()
+
+	switch ent.Level {
+	case TraceLevel, zapcore.DebugLevel:
+		return c.w.Debug(msg)
+	case zapcore.InfoLevel:
+		return c.w.Info(msg)
+	case zapcore.WarnLevel:
+		return c.w.Warning(msg)
+	case zapcore.ErrorLevel:
+		return c.w.Err(msg)
+	}
+	return c.w.Crit(msg)
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package fllog
+
+import (
+	"errors"
+	"io"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func newSyslogCore(sink SinkCfg, enc zapcore.Encoder, enabler zapcore.LevelEnabler) (zapcore.Core, io.Closer, error) {
+	return nil, nil, errors.New("syslog sink not supported on this platform")
+}
@@ -0,0 +1,35 @@
+module github.com/xiaolongdeng1990/forlife/MSF/metrics
+
+go 1.20
+
+require (
+	github.com/prometheus/client_golang v1.19.1
+	github.com/xiaolongdeng1990/forlife/MSF/errors v0.0.0-00010101000000-000000000000
+	github.com/xiaolongdeng1990/forlife/MSF/log v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/BurntSushi/toml v1.3.2 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/fatih/color v1.16.0 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	github.com/xiaolongdeng1990/forlife/MSF/config v0.0.0-20240420121952-aea7746477b6 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	go.uber.org/zap v1.27.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace (
+	github.com/xiaolongdeng1990/forlife/MSF/config => ../config
+	github.com/xiaolongdeng1990/forlife/MSF/errors => ../errors
+	github.com/xiaolongdeng1990/forlife/MSF/log => ../log
+)
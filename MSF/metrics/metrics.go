@@ -0,0 +1,184 @@
+package flmetrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	flerrors "github.com/xiaolongdeng1990/forlife/MSF/errors"
+	fllog "github.com/xiaolongdeng1990/forlife/MSF/log"
+)
+
+const (
+	namespace = "forlife"
+
+	// DefaultPath 默认的metrics路径
+	DefaultPath = "/metrics"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	// Server 服务端RED指标, 由flsvr自动记录
+	Server = newObserver("server")
+	// Client 客户端RED指标, 由flcli自动记录
+	Client = newObserver("client")
+
+	discovery = &discoveryCollector{services: make(map[string]map[int]func() int)}
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Server.requests, Server.latency, Server.inFlight,
+		Client.requests, Client.latency, Client.inFlight,
+		discovery,
+		logDropped("overflow", fllog.AsyncDropped),
+		logDropped("sampled", fllog.SampledDropped),
+	)
+}
+
+// logDropped fllog因异步队列满或采样丢弃的日志条数
+func logDropped(reason string, dropped func() uint64) prometheus.CounterFunc {
+	return prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   namespace,
+		Subsystem:   "log",
+		Name:        "dropped_total",
+		Help:        "Number of log entries dropped by fllog.",
+		ConstLabels: prometheus.Labels{"reason": reason},
+	}, func() float64 { return float64(dropped()) })
+}
+
+// Registry 框架使用的prometheus Registry, 业务指标也可以注册到这里一起导出
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+// Handler Prometheus文本格式的/metrics处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// NewServer 创建只提供metrics的HTTP服务, path为空时使用DefaultPath
+func NewServer(addr, path string) *http.Server {
+	if len(path) == 0 {
+		path = DefaultPath
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, Handler())
+	return &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+}
+
+// Observer 一组按service/method统计的请求数(按错误码)、耗时与并发数
+type Observer struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newObserver(subsystem string) *Observer {
+	labels := []string{"service", "method"}
+	return &Observer{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Total number of RPC requests by result code.",
+		}, append(labels, "code")),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "RPC request latency in seconds.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "in_flight_requests",
+			Help:      "Number of RPC requests currently being processed.",
+		}, labels),
+	}
+}
+
+// Begin 记录一次请求开始, 请求结束时以返回的错误调用done, 错误码取flerrors.CodeOf
+func (o *Observer) Begin(service, method string) (done func(err error)) {
+	start := time.Now()
+	inFlight := o.inFlight.WithLabelValues(service, method)
+	inFlight.Inc()
+	return func(err error) {
+		inFlight.Dec()
+		o.latency.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+		o.requests.WithLabelValues(service, method, flerrors.CodeOf(err).String()).Inc()
+	}
+}
+
+// WatchDiscovery 登记service的服务发现, 采集时调用instances取当前可用节点数,
+// 返回的函数用于注销. 同一service登记多次时取节点数最多的一个
+func WatchDiscovery(service string, instances func() int) (unwatch func()) {
+	return discovery.add(service, instances)
+}
+
+var (
+	discoveryInstancesDesc = prometheus.NewDesc(namespace+"_discovery_instances",
+		"Number of service instances currently returned by discovery.", []string{"service"}, nil)
+	discoveryUpDesc = prometheus.NewDesc(namespace+"_discovery_up",
+		"Whether discovery currently returns at least one instance (1) or none (0).", []string{"service"}, nil)
+)
+
+type discoveryCollector struct {
+	mu       sync.Mutex
+	nextID   int
+	services map[string]map[int]func() int
+}
+
+func (d *discoveryCollector) add(service string, instances func() int) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	id := d.nextID
+	if d.services[service] == nil {
+		d.services[service] = make(map[int]func() int)
+	}
+	d.services[service][id] = instances
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			delete(d.services[service], id)
+			if len(d.services[service]) == 0 {
+				delete(d.services, service)
+			}
+		})
+	}
+}
+
+func (d *discoveryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- discoveryInstancesDesc
+	ch <- discoveryUpDesc
+}
+
+func (d *discoveryCollector) Collect(ch chan<- prometheus.Metric) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for service, funcs := range d.services {
+		n := 0
+		for _, instances := range funcs {
+			if c := instances(); c > n {
+				n = c
+			}
+		}
+		up := 0.0
+		if n > 0 {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(discoveryInstancesDesc, prometheus.GaugeValue, float64(n), service)
+		ch <- prometheus.MustNewConstMetric(discoveryUpDesc, prometheus.GaugeValue, up, service)
+	}
+}
@@ -0,0 +1,200 @@
+package registry
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rpcxio/libkv"
+	"github.com/rpcxio/libkv/store"
+	cclient "github.com/rpcxio/rpcx-consul/client"
+	cserver "github.com/rpcxio/rpcx-consul/serverplugin"
+	rclient "github.com/smallnest/rpcx/client"
+)
+
+type consulRegistry struct {
+	addrs          []string
+	updateInterval time.Duration
+
+	mu      sync.Mutex
+	plugins map[string]*cserver.ConsulRegisterPlugin // basePath+addr -> plugin
+}
+
+// NewConsul 基于consul kv的注册中心, 与rpcx-consul的注册格式一致
+func NewConsul(addrs []string, updateInterval time.Duration) (Registry, error) {
+	var valid []string
+	for _, addr := range addrs {
+		if len(addr) > 0 {
+			valid = append(valid, addr)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, ErrEmptyAddrs
+	}
+	if updateInterval <= 0 {
+		updateInterval = time.Minute
+	}
+	return &consulRegistry{
+		addrs:          valid,
+		updateInterval: updateInterval,
+		plugins:        make(map[string]*cserver.ConsulRegisterPlugin),
+	}, nil
+}
+
+func (r *consulRegistry) Register(ins Instance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := ins.BasePath + "|" + ins.Addr
+	p, ok := r.plugins[key]
+	if !ok {
+		p = &cserver.ConsulRegisterPlugin{
+			ServiceAddress: ins.Addr,
+			ConsulServers:  r.addrs,
+			BasePath:       ins.BasePath,
+			UpdateInterval: r.updateInterval,
+		}
+		if err := p.Start(); err != nil {
+			return err
+		}
+		r.plugins[key] = p
+	}
+	return p.Register(ins.SvrName, nil, ins.Metadata)
+}
+
+func (r *consulRegistry) Deregister(ins Instance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := ins.BasePath + "|" + ins.Addr
+	p, ok := r.plugins[key]
+	if !ok {
+		return nil
+	}
+	err := p.Unregister(ins.SvrName)
+	if len(p.Services) == 0 {
+		// 没有服务了, 停掉TTL刷新
+		delete(r.plugins, key)
+		if stopErr := p.Stop(); err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
+
+func (r *consulRegistry) Resolve(basePath, svrName string) ([]Instance, error) {
+	kv, err := libkv.NewStore(store.CONSUL, r.addrs, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer kv.Close()
+
+	path := strings.TrimPrefix(basePath, "/") + "/" + svrName
+	ps, err := kv.List(path)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+	prefix := path + "/"
+	instances := make([]Instance, 0, len(ps))
+	for _, p := range ps {
+		if !strings.HasPrefix(p.Key, prefix) {
+			continue
+		}
+		instances = append(instances, Instance{
+			BasePath: basePath,
+			SvrName:  svrName,
+			Addr:     strings.TrimPrefix(p.Key, prefix),
+			Metadata: string(p.Value),
+		})
+	}
+	return instances, nil
+}
+
+func (r *consulRegistry) Watch(basePath, svrName string) (Watcher, error) {
+	d, err := cclient.NewConsulDiscovery(basePath, svrName, r.addrs, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &consulWatcher{
+		d:      d,
+		src:    d.WatchService(),
+		ch:     make(chan []Instance, 1),
+		stopCh: make(chan struct{}),
+	}
+	go w.loop(basePath, svrName)
+	return w, nil
+}
+
+// Discovery 直接使用rpcx-consul的服务发现, 省去一层转换
+func (r *consulRegistry) Discovery(basePath, svrName string) (rclient.ServiceDiscovery, error) {
+	return cclient.NewConsulDiscovery(basePath, svrName, r.addrs, nil)
+}
+
+func (r *consulRegistry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for key, p := range r.plugins {
+		if stopErr := p.Stop(); stopErr != nil && err == nil {
+			err = stopErr
+		}
+		delete(r.plugins, key)
+	}
+	return err
+}
+
+type consulWatcher struct {
+	d        *cclient.ConsulDiscovery
+	src      chan []*rclient.KVPair
+	ch       chan []Instance
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+func (w *consulWatcher) loop(basePath, svrName string) {
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case pairs := <-w.src:
+			instances := make([]Instance, 0, len(pairs))
+			for _, p := range pairs {
+				instances = append(instances, Instance{
+					BasePath: basePath,
+					SvrName:  svrName,
+					Addr:     p.Key,
+					Metadata: p.Value,
+				})
+			}
+			notify(w.ch, instances)
+		}
+	}
+}
+
+func (w *consulWatcher) Changes() <-chan []Instance {
+	return w.ch
+}
+
+func (w *consulWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+		w.d.RemoveWatcher(w.src)
+		w.d.Close()
+	})
+}
+
+// notify 只保留最新一次变更, 消费慢时丢弃旧的列表
+func notify(ch chan []Instance, instances []Instance) {
+	for {
+		select {
+		case ch <- instances:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
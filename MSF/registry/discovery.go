@@ -0,0 +1,155 @@
+package registry
+
+import (
+	"sync"
+
+	rclient "github.com/smallnest/rpcx/client"
+)
+
+// discoverer 可以直接提供rpcx服务发现的注册中心, 如consul
+type discoverer interface {
+	Discovery(basePath, svrName string) (rclient.ServiceDiscovery, error)
+}
+
+// NewDiscovery 将Registry适配为rpcx的ServiceDiscovery, 供XClient使用
+func NewDiscovery(r Registry, basePath, svrName string) (rclient.ServiceDiscovery, error) {
+	if r == nil {
+		return nil, ErrNilRegistry
+	}
+	if d, ok := r.(discoverer); ok {
+		return d.Discovery(basePath, svrName)
+	}
+
+	// 先Watch再Resolve, 两者之间的变更会留在Changes中由loop补上
+	w, err := r.Watch(basePath, svrName)
+	if err != nil {
+		return nil, err
+	}
+	instances, err := r.Resolve(basePath, svrName)
+	if err != nil {
+		w.Stop()
+		return nil, err
+	}
+	d := &discovery{
+		r:        r,
+		basePath: basePath,
+		w:        w,
+		stopCh:   make(chan struct{}),
+	}
+	d.instances = instances
+	d.pairs = d.toPairs(instances)
+	go d.loop()
+	return d, nil
+}
+
+type discovery struct {
+	r        Registry
+	basePath string
+	w        Watcher
+
+	// pairsMu 保护instances、pairs与filter, SetFilter时按原始列表重新过滤
+	pairsMu   sync.RWMutex
+	instances []Instance
+	pairs     []*rclient.KVPair
+	filter    rclient.ServiceDiscoveryFilter
+
+	mu    sync.Mutex
+	chans []chan []*rclient.KVPair
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+func (d *discovery) loop() {
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case instances, ok := <-d.w.Changes():
+			if !ok {
+				return
+			}
+			d.pairsMu.Lock()
+			d.instances = instances
+			pairs := d.toPairs(instances)
+			d.pairs = pairs
+			d.pairsMu.Unlock()
+			d.broadcast(pairs)
+		}
+	}
+}
+
+func (d *discovery) broadcast(pairs []*rclient.KVPair) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, ch := range d.chans {
+		select {
+		case ch <- pairs:
+		default:
+			// XClient消费慢时丢弃, 下次变更会带上全量列表
+		}
+	}
+}
+
+// toPairs 调用方持有pairsMu
+func (d *discovery) toPairs(instances []Instance) []*rclient.KVPair {
+	pairs := make([]*rclient.KVPair, 0, len(instances))
+	for _, ins := range instances {
+		pair := &rclient.KVPair{Key: ins.Addr, Value: ins.Metadata}
+		if d.filter != nil && !d.filter(pair) {
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+func (d *discovery) GetServices() []*rclient.KVPair {
+	d.pairsMu.RLock()
+	defer d.pairsMu.RUnlock()
+	return d.pairs
+}
+
+func (d *discovery) WatchService() chan []*rclient.KVPair {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch := make(chan []*rclient.KVPair, 10)
+	d.chans = append(d.chans, ch)
+	return ch
+}
+
+func (d *discovery) RemoveWatcher(ch chan []*rclient.KVPair) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var chans []chan []*rclient.KVPair
+	for _, c := range d.chans {
+		if c == ch {
+			continue
+		}
+		chans = append(chans, c)
+	}
+	d.chans = chans
+}
+
+func (d *discovery) Clone(servicePath string) (rclient.ServiceDiscovery, error) {
+	return NewDiscovery(d.r, d.basePath, servicePath)
+}
+
+// SetFilter 替换过滤条件, 并按新条件重新过滤当前列表
+func (d *discovery) SetFilter(filter rclient.ServiceDiscoveryFilter) {
+	d.pairsMu.Lock()
+	d.filter = filter
+	pairs := d.toPairs(d.instances)
+	d.pairs = pairs
+	d.pairsMu.Unlock()
+	d.broadcast(pairs)
+}
+
+func (d *discovery) Close() {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+		d.w.Stop()
+	})
+}
@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	rclient "github.com/smallnest/rpcx/client"
+)
+
+func addrs(pairs []*rclient.KVPair) string {
+	keys := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		keys = append(keys, p.Key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// waitServices 等loop处理完变更
+func waitServices(t *testing.T, d rclient.ServiceDiscovery, want string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for addrs(d.GetServices()) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("services = %q, want %q", addrs(d.GetServices()), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	m := NewMemory()
+	a := Instance{BasePath: "demo", SvrName: "Math", Addr: "tcp@a:1", Metadata: "group=x"}
+	b := Instance{BasePath: "demo", SvrName: "Math", Addr: "tcp@b:1", Metadata: "group=y"}
+	m.Register(a)
+
+	d, err := NewDiscovery(m, "demo", "Math")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	waitServices(t, d, "tcp@a:1")
+
+	ch := d.WatchService()
+	m.Register(b)
+	waitServices(t, d, "tcp@a:1,tcp@b:1")
+	if got := addrs(<-ch); got != "tcp@a:1,tcp@b:1" {
+		t.Errorf("watch = %q", got)
+	}
+
+	// SetFilter按原始列表重新过滤, 取消过滤后恢复
+	d.SetFilter(func(p *rclient.KVPair) bool { return p.Value == "group=y" })
+	if got := addrs(d.GetServices()); got != "tcp@b:1" {
+		t.Errorf("filtered = %q", got)
+	}
+	if got := addrs(<-ch); got != "tcp@b:1" {
+		t.Errorf("watch after SetFilter = %q", got)
+	}
+	m.Deregister(b)
+	waitServices(t, d, "")
+	m.Register(b)
+	waitServices(t, d, "tcp@b:1")
+	d.SetFilter(nil)
+	if got := addrs(d.GetServices()); got != "tcp@a:1,tcp@b:1" {
+		t.Errorf("unfiltered = %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"sync"
+)
+
+// Memory 进程内注册中心, 同进程内的FLSvr与FlClient无需consul即可互通, 主要用于测试
+type Memory struct {
+	mu       sync.Mutex
+	services map[string][]Instance
+	watchers map[string]map[*memoryWatcher]struct{}
+}
+
+var (
+	onceMemory     sync.Once
+	memoryInstance *Memory
+)
+
+// NewMemory 创建一个独立的进程内注册中心
+func NewMemory() *Memory {
+	return &Memory{
+		services: make(map[string][]Instance),
+		watchers: make(map[string]map[*memoryWatcher]struct{}),
+	}
+}
+
+// DefaultMemory 进程内共享的注册中心, 配置Type=memory时使用
+func DefaultMemory() *Memory {
+	onceMemory.Do(func() {
+		memoryInstance = NewMemory()
+	})
+	return memoryInstance
+}
+
+func (m *Memory) Register(ins Instance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := serviceKey(ins.BasePath, ins.SvrName)
+	instances := m.services[key]
+	for i := range instances {
+		if instances[i].Addr == ins.Addr {
+			instances[i] = ins
+			m.notifyLocked(key)
+			return nil
+		}
+	}
+	m.services[key] = append(instances, ins)
+	m.notifyLocked(key)
+	return nil
+}
+
+func (m *Memory) Deregister(ins Instance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := serviceKey(ins.BasePath, ins.SvrName)
+	instances := m.services[key]
+	for i := range instances {
+		if instances[i].Addr == ins.Addr {
+			m.services[key] = append(instances[:i:i], instances[i+1:]...)
+			m.notifyLocked(key)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Resolve(basePath, svrName string) ([]Instance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.copyLocked(serviceKey(basePath, svrName)), nil
+}
+
+func (m *Memory) Watch(basePath, svrName string) (Watcher, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := serviceKey(basePath, svrName)
+	w := &memoryWatcher{m: m, key: key, ch: make(chan []Instance, 1)}
+	if m.watchers[key] == nil {
+		m.watchers[key] = make(map[*memoryWatcher]struct{})
+	}
+	m.watchers[key][w] = struct{}{}
+	return w, nil
+}
+
+// Close 进程内注册中心没有需要释放的资源
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) copyLocked(key string) []Instance {
+	instances := make([]Instance, len(m.services[key]))
+	copy(instances, m.services[key])
+	return instances
+}
+
+func (m *Memory) notifyLocked(key string) {
+	for w := range m.watchers[key] {
+		notify(w.ch, m.copyLocked(key))
+	}
+}
+
+type memoryWatcher struct {
+	m   *Memory
+	key string
+	ch  chan []Instance
+}
+
+func (w *memoryWatcher) Changes() <-chan []Instance {
+	return w.ch
+}
+
+func (w *memoryWatcher) Stop() {
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+
+	if _, ok := w.m.watchers[w.key][w]; ok {
+		delete(w.m.watchers[w.key], w)
+		close(w.ch)
+	}
+}
@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiaolongdeng1990/forlife/MSF/config"
+	"github.com/xiaolongdeng1990/forlife/MSF/consul"
+)
+
+// 注册中心类型
+const (
+	TypeConsul = "consul"
+	TypeStatic = "static"
+	TypeMemory = "memory"
+)
+
+// Instance 一个服务实例, 对应rpcx中 basePath/svrName/addr 的一个节点
+type Instance struct {
+	BasePath string // 如 rpcx_test
+	SvrName  string // 如 Demo
+	Addr     string // 如 tcp@127.0.0.1:8972
+	Metadata string // url query格式的元数据
+}
+
+// Watcher 服务实例变更通知
+type Watcher interface {
+	// Changes 每次实例列表变化时推送最新的全量列表
+	Changes() <-chan []Instance
+	Stop()
+}
+
+// Registry 服务注册与发现
+type Registry interface {
+	Register(ins Instance) error
+	Deregister(ins Instance) error
+	Resolve(basePath, svrName string) ([]Instance, error)
+	Watch(basePath, svrName string) (Watcher, error)
+	Close() error
+}
+
+// StaticService 静态地址列表中的一个服务
+type StaticService struct {
+	Name  string   // basePath.SvrName, 如 rpcx_test.Demo
+	Addrs []string // 如 tcp@127.0.0.1:8972, 不带network时默认tcp
+}
+
+// RegistryCfg 注册中心配置
+type RegistryCfg struct {
+	Registry struct {
+		Type           string          `default:"consul"` // consul | static | memory
+		Addrs          []string        `default:""`       // consul地址, 为空时使用Server.ConsulAddr
+		UpdateInterval config.Duration `default:"1m"`     // consul TTL刷新间隔
+		Static         []StaticService // Type=static时的服务列表
+	}
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRegistry Registry
+)
+
+// Init 从配置文件加载注册中心并设为默认
+func Init(cfg string) error {
+	r, err := Load(cfg)
+	if err != nil {
+		return err
+	}
+	SetDefault(r)
+	return nil
+}
+
+// Load 从配置文件加载注册中心
+func Load(cfg string) (Registry, error) {
+	regCfg := RegistryCfg{}
+	if _, err := config.Load(&regCfg, cfg); err != nil {
+		return nil, fmt.Errorf("load registry cfg %s: %w", cfg, err)
+	}
+	return New(regCfg)
+}
+
+// New 根据配置创建注册中心
+func New(regCfg RegistryCfg) (Registry, error) {
+	c := regCfg.Registry
+	switch strings.ToLower(c.Type) {
+	case "", TypeConsul:
+		addrs := c.Addrs
+		if len(addrs) == 0 && len(consul.GetConsulAddr()) > 0 {
+			addrs = []string{consul.GetConsulAddr()}
+		}
+		return NewConsul(addrs, c.UpdateInterval.Duration())
+	case TypeStatic:
+		return NewStatic(c.Static), nil
+	case TypeMemory:
+		return DefaultMemory(), nil
+	}
+	return nil, fmt.Errorf("not support registry type %v", c.Type)
+}
+
+// SetDefault 设置进程内默认注册中心, FLSvr与FlClient未指定时使用
+func SetDefault(r Registry) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRegistry = r
+}
+
+// Default 返回默认注册中心, 未设置时使用consul.GetConsulAddr()对应的consul
+func Default() (Registry, error) {
+	defaultMu.RLock()
+	r := defaultRegistry
+	defaultMu.RUnlock()
+	if r != nil {
+		return r, nil
+	}
+	return NewConsul([]string{consul.GetConsulAddr()}, time.Minute)
+}
+
+var (
+	// ErrEmptyAddrs 注册中心地址为空
+	ErrEmptyAddrs = errors.New("registry addrs empty")
+	// ErrNilRegistry 未指定注册中心
+	ErrNilRegistry = errors.New("registry is nil")
+)
+
+func serviceKey(basePath, svrName string) string {
+	return strings.TrimPrefix(basePath, "/") + "." + svrName
+}
@@ -0,0 +1,70 @@
+package registry
+
+import (
+	"strings"
+	"sync"
+)
+
+type staticRegistry struct {
+	services map[string][]Instance
+}
+
+// NewStatic 固定地址列表的注册中心, 注册与注销都是空操作, 适合本地开发
+func NewStatic(services []StaticService) Registry {
+	r := &staticRegistry{services: make(map[string][]Instance)}
+	for _, svc := range services {
+		name := strings.TrimPrefix(svc.Name, "/")
+		idx := strings.LastIndex(name, ".")
+		if idx <= 0 || idx == len(name)-1 {
+			continue
+		}
+		basePath, svrName := name[:idx], name[idx+1:]
+		for _, addr := range svc.Addrs {
+			if !strings.Contains(addr, "@") {
+				addr = "tcp@" + addr
+			}
+			r.services[name] = append(r.services[name], Instance{
+				BasePath: basePath,
+				SvrName:  svrName,
+				Addr:     addr,
+			})
+		}
+	}
+	return r
+}
+
+func (r *staticRegistry) Register(ins Instance) error {
+	return nil
+}
+
+func (r *staticRegistry) Deregister(ins Instance) error {
+	return nil
+}
+
+func (r *staticRegistry) Resolve(basePath, svrName string) ([]Instance, error) {
+	return r.services[serviceKey(basePath, svrName)], nil
+}
+
+func (r *staticRegistry) Watch(basePath, svrName string) (Watcher, error) {
+	return &staticWatcher{ch: make(chan []Instance)}, nil
+}
+
+func (r *staticRegistry) Close() error {
+	return nil
+}
+
+// staticWatcher 地址列表不会变化, 永远不推送
+type staticWatcher struct {
+	ch       chan []Instance
+	stopOnce sync.Once
+}
+
+func (w *staticWatcher) Changes() <-chan []Instance {
+	return w.ch
+}
+
+func (w *staticWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.ch)
+	})
+}
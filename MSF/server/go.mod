@@ -1,14 +1,17 @@
 module github.com/xiaolongdeng1990/forlife/MSF/server
 
-go 1.20
+go 1.21
 
 require (
-	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475
-	github.com/rpcxio/rpcx-consul v0.0.0-20230904043151-f6175fbe2f72
-	github.com/smallnest/rpcx v1.8.29
+	github.com/smallnest/rpcx v1.8.30
+	github.com/xiaolongdeng1990/forlife/MSF/client v0.0.0-00010101000000-000000000000
 	github.com/xiaolongdeng1990/forlife/MSF/config v0.0.0-20240423133039-064980ade61d
-	github.com/xiaolongdeng1990/forlife/MSF/consul v0.0.0-20240426124830-4efd920762d7
+	github.com/xiaolongdeng1990/forlife/MSF/consul v0.0.0-20240427023951-cd5e012ea9d6
+	github.com/xiaolongdeng1990/forlife/MSF/errors v0.0.0-00010101000000-000000000000
 	github.com/xiaolongdeng1990/forlife/MSF/log v0.0.0-20240420130217-d648914eafdc
+	github.com/xiaolongdeng1990/forlife/MSF/metrics v0.0.0-00010101000000-000000000000
+	github.com/xiaolongdeng1990/forlife/MSF/registry v0.0.0-00010101000000-000000000000
+	github.com/xiaolongdeng1990/forlife/MSF/trace v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -17,22 +20,35 @@ require (
 	github.com/alitto/pond v1.8.3 // indirect
 	github.com/apache/thrift v0.18.1 // indirect
 	github.com/armon/go-metrics v0.4.0 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cenk/backoff v2.2.1+incompatible // indirect
+	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-jump v0.0.0-20211018200510-ba001c3ffce0 // indirect
+	github.com/edwingeng/doublejump v1.0.1 // indirect
+	github.com/facebookgo/clock v0.0.0-20150410010913-600d898af40a // indirect
 	github.com/fatih/color v1.16.0 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/go-echarts/go-echarts/v2 v2.3.2 // indirect
+	github.com/go-ping/ping v1.1.0 // indirect
 	github.com/go-task/slim-sprig v0.0.0-20230315185526-52ccab3ef572 // indirect
 	github.com/godzie44/go-uring v0.0.0-20220926161041-69611e8b13d5 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang/snappy v0.0.4 // indirect
 	github.com/google/pprof v0.0.0-20230228050547-1710fef4ab10 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/grandcat/zeroconf v1.0.0 // indirect
 	github.com/hashicorp/consul/api v1.13.0 // indirect
+	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
 	github.com/hashicorp/go-hclog v1.2.1 // indirect
 	github.com/hashicorp/go-immutable-radix v1.3.1 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/hashicorp/go-rootcerts v1.0.2 // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
 	github.com/hashicorp/serf v0.9.8 // indirect
 	github.com/jamiealquiza/tachymeter v2.0.0+incompatible // indirect
-	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/juju/ratelimit v1.0.2 // indirect
 	github.com/julienschmidt/httprouter v1.3.0 // indirect
 	github.com/kavu/go_reuseport v1.5.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.4 // indirect
@@ -40,16 +56,22 @@ require (
 	github.com/libp2p/go-sockaddr v0.1.1 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/miekg/dns v1.1.51 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
-	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
-	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/onsi/ginkgo/v2 v2.9.5 // indirect
 	github.com/philhofer/fwd v1.1.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
+	github.com/prometheus/client_golang v1.19.1 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
 	github.com/quic-go/quic-go v0.42.0 // indirect
+	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
 	github.com/rpcxio/libkv v0.5.1 // indirect
+	github.com/rpcxio/rpcx-consul v0.0.0-20230904043151-f6175fbe2f72 // indirect
 	github.com/rs/cors v1.8.3 // indirect
+	github.com/rubyist/circuitbreaker v2.2.1+incompatible // indirect
 	github.com/smallnest/quick v0.1.0 // indirect
 	github.com/smallnest/statsview v0.0.0-20231119085602-10700f9abec4 // indirect
 	github.com/soheilhy/cmux v0.1.5 // indirect
@@ -58,19 +80,33 @@ require (
 	github.com/templexxx/xor v0.0.0-20191217153810-f85b25db303b // indirect
 	github.com/tinylib/msgp v1.1.8 // indirect
 	github.com/tjfoc/gmsm v1.4.1 // indirect
+	github.com/valyala/fastrand v1.1.0 // indirect
 	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	github.com/xtaci/kcp-go v5.4.20+incompatible // indirect
 	go.uber.org/mock v0.4.0 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
-	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/crypto v0.18.0 // indirect
 	golang.org/x/exp v0.0.0-20230307190834-24139beb5833 // indirect
 	golang.org/x/mod v0.11.0 // indirect
-	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	golang.org/x/tools v0.9.1 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace (
+	github.com/xiaolongdeng1990/forlife/MSF/client => ../client
+	github.com/xiaolongdeng1990/forlife/MSF/config => ../config
+	github.com/xiaolongdeng1990/forlife/MSF/consul => ../consul
+	github.com/xiaolongdeng1990/forlife/MSF/errors => ../errors
+	github.com/xiaolongdeng1990/forlife/MSF/log => ../log
+	github.com/xiaolongdeng1990/forlife/MSF/metrics => ../metrics
+	github.com/xiaolongdeng1990/forlife/MSF/registry => ../registry
+	github.com/xiaolongdeng1990/forlife/MSF/trace => ../trace
 )
@@ -0,0 +1,219 @@
+package flsvr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/smallnest/rpcx/share"
+	flerrors "github.com/xiaolongdeng1990/forlife/MSF/errors"
+	fllog "github.com/xiaolongdeng1990/forlife/MSF/log"
+	flmetrics "github.com/xiaolongdeng1990/forlife/MSF/metrics"
+	fltrace "github.com/xiaolongdeng1990/forlife/MSF/trace"
+)
+
+// logger flsvr的模块logger, 可用 LogConf.Modules 中的 server = "DEBUG" 单独调整级别
+var logger = fllog.Named("server")
+
+// Handler 业务处理函数, reply由框架持有, 可通过ReplyFromContext取得
+type Handler func(ctx context.Context, req interface{}) error
+
+// Interceptor 服务端拦截器, 调用next进入下一个拦截器, 最后一个next即业务处理函数.
+// 传给next的ctx需由入参ctx派生
+type Interceptor func(ctx context.Context, serviceMethod string, req interface{}, next Handler) error
+
+// Validator 请求参数实现该接口时, Validate拦截器会在处理前校验
+type Validator interface {
+	Validate() error
+}
+
+// callKey ctx中保存本次调用rpcx传入的原始参数, 业务函数最终用它调用
+type callKey struct{}
+
+// ReplyFromContext 在拦截器中取得本次请求的reply, next返回后即为处理结果
+func ReplyFromContext(ctx context.Context) interface{} {
+	if in, ok := ctx.Value(callKey{}).([]reflect.Value); ok {
+		return in[2].Interface()
+	}
+	return nil
+}
+
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// Use 按顺序追加拦截器, 先追加的在外层. 需在StartServer之前调用, 已注册的接口会重新组装拦截器链
+func (f *FLSvr) Use(interceptors ...Interceptor) {
+	f.chainMu.Lock()
+	defer f.chainMu.Unlock()
+	f.interceptors = append(f.interceptors, interceptors...)
+	for _, m := range f.methods {
+		m.build(f.interceptors)
+	}
+}
+
+// method 一个已注册的接口, 拦截器链在注册与Use时组装, 请求时直接调用
+type method struct {
+	serviceMethod string
+	fn            reflect.Value
+	handler       atomic.Pointer[Handler]
+}
+
+// build 将拦截器与业务处理函数串成一个Handler
+func (m *method) build(interceptors []Interceptor) {
+	h := Handler(m.call)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], h
+		h = func(ctx context.Context, req interface{}) error {
+			return interceptor(ctx, m.serviceMethod, req, next)
+		}
+	}
+	m.handler.Store(&h)
+}
+
+// call 链路的最后一环, 拦截器替换了ctx/req时, 类型匹配才传给业务函数
+func (m *method) call(ctx context.Context, req interface{}) error {
+	var in []reflect.Value
+	if ctx != nil {
+		in, _ = ctx.Value(callKey{}).([]reflect.Value)
+	}
+	if in == nil {
+		return flerrors.Newf(flerrors.Internal, "%s: ctx passed to next is not derived from the interceptor ctx", m.serviceMethod)
+	}
+	args := []reflect.Value{in[0], in[1], in[2]}
+	if v := reflect.ValueOf(ctx); v.Type().AssignableTo(in[0].Type()) {
+		args[0] = v
+	}
+	if v := reflect.ValueOf(req); req != nil && v.Type().AssignableTo(in[1].Type()) {
+		args[1] = v
+	}
+	err, _ := m.fn.Call(args)[0].Interface().(error)
+	return err
+}
+
+// wrap 生成与fn签名相同的函数, 调用时先经过拦截器链
+func (f *FLSvr) wrap(serviceMethod string, fn reflect.Value) reflect.Value {
+	m := &method{serviceMethod: serviceMethod, fn: fn}
+	f.chainMu.Lock()
+	m.build(f.interceptors)
+	f.methods = append(f.methods, m)
+	f.chainMu.Unlock()
+
+	method := strings.TrimPrefix(serviceMethod, f.svrName+".")
+	return reflect.MakeFunc(fn.Type(), func(in []reflect.Value) []reflect.Value {
+		// 接续调用方的链路, trace_id/request_id/service/method随ctx带到日志中
+		ctx := in[0].Interface().(context.Context)
+		reqMeta, _ := ctx.Value(share.ReqMetaDataKey).(map[string]string)
+		ctx, span := fltrace.StartSpan(fltrace.Extract(ctx, reqMeta), serviceMethod, fltrace.KindServer)
+		sc := span.SpanContext()
+		requestID := reqMeta[fltrace.RequestIDKey]
+		if len(requestID) == 0 {
+			requestID = fltrace.NewRequestID()
+		}
+		ctx = fllog.NewContext(ctx,
+			fllog.FieldTraceID, sc.TraceID.String(),
+			fllog.FieldSpanID, sc.SpanID.String(),
+			fllog.FieldRequestID, requestID,
+			fllog.FieldService, f.svrName,
+			fllog.FieldMethod, method)
+
+		done := flmetrics.Server.Begin(f.svrName, method)
+		defer func() {
+			// 未装Recovery时panic交给rpcx处理, 这里只补记指标和span
+			if r := recover(); r != nil {
+				err := flerrors.Newf(flerrors.Internal, "%s panic: %v", serviceMethod, r)
+				done(err)
+				span.SetError(err)
+				span.End()
+				panic(r)
+			}
+		}()
+
+		ctx = context.WithValue(ctx, callKey{}, in)
+		err := (*m.handler.Load())(ctx, in[1].Interface())
+		done(err)
+		span.SetError(err)
+		span.End()
+		if err == nil {
+			return []reflect.Value{reflect.Zero(typeOfError)}
+		}
+		// 错误码随响应元数据回传, 客户端据此还原出flerrors.Error
+		if meta, ok := ctx.Value(share.ResMetaDataKey).(map[string]string); ok {
+			flerrors.ToMetadata(err, meta)
+		}
+		return []reflect.Value{reflect.ValueOf(&err).Elem()}
+	})
+}
+
+// funcName 取函数名作为接口名, 与rpcx RegisterFunction的规则一致
+func funcName(fn reflect.Value) string {
+	name := runtime.FuncForPC(fn.Pointer()).Name()
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '.' {
+			return name[i+1:]
+		}
+	}
+	return name
+}
+
+// AccessLog 记录每个请求的req/reply/耗时
+func AccessLog() Interceptor {
+	return func(ctx context.Context, serviceMethod string, req interface{}, next Handler) error {
+		start := time.Now()
+		err := next(ctx, req)
+		cost := time.Since(start)
+		if err != nil {
+			logger.ErrorCtx(ctx, "access", "req", req, "cost", cost.String(), "code", flerrors.CodeOf(err).String(), "err", err)
+			return err
+		}
+		logger.InfoCtx(ctx, "access", "req", req, "reply", ReplyFromContext(ctx), "cost", cost.String())
+		return nil
+	}
+}
+
+// Recovery 捕获业务处理中的panic并转为错误返回, 避免调用方一直等到超时
+func Recovery() Interceptor {
+	return func(ctx context.Context, serviceMethod string, req interface{}, next Handler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				buf := make([]byte, 4096)
+				buf = buf[:runtime.Stack(buf, false)]
+				logger.ErrorCtx(ctx, "panic", "req", req, "panic", fmt.Sprint(r), "stack", string(buf))
+				err = flerrors.Newf(flerrors.Internal, "%s panic: %v", serviceMethod, r)
+			}
+		}()
+		return next(ctx, req)
+	}
+}
+
+// Timing 统计处理耗时, 超过threshold的请求打印慢日志, 其余以DEBUG级别打印
+func Timing(threshold time.Duration) Interceptor {
+	return func(ctx context.Context, serviceMethod string, req interface{}, next Handler) error {
+		start := time.Now()
+		err := next(ctx, req)
+		cost := time.Since(start)
+		if threshold > 0 && cost >= threshold {
+			logger.WarnCtx(ctx, "slow request", "cost", cost.String(), "threshold", threshold.String())
+		} else {
+			logger.DebugCtx(ctx, "request cost", "cost", cost.String())
+		}
+		return err
+	}
+}
+
+// Validate 请求实现了Validator时先校验, 不通过直接返回错误
+func Validate() Interceptor {
+	return func(ctx context.Context, serviceMethod string, req interface{}, next Handler) error {
+		if v, ok := req.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return flerrors.Wrap(err, flerrors.InvalidArgument, serviceMethod+" invalid request: "+err.Error())
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -1,28 +1,50 @@
 package flsvr
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	metrics "github.com/rcrowley/go-metrics"
-	cserver "github.com/rpcxio/rpcx-consul/serverplugin"
 	rpcx_svr "github.com/smallnest/rpcx/server"
 
+	flcli "github.com/xiaolongdeng1990/forlife/MSF/client"
 	"github.com/xiaolongdeng1990/forlife/MSF/config"
 	consul "github.com/xiaolongdeng1990/forlife/MSF/consul"
 	fllog "github.com/xiaolongdeng1990/forlife/MSF/log"
+	flmetrics "github.com/xiaolongdeng1990/forlife/MSF/metrics"
+	"github.com/xiaolongdeng1990/forlife/MSF/registry"
 )
 
 type SvrCfg struct {
 	Server struct {
-		Name       string `default:""`
-		Address    string `default:""`
-		ConsulAddr string `default:""`
+		Name       string `default:"" validate:"required"` // basePath.SvrName
+		Address    string `default:"" validate:"required,hostport"`
+		ConsulAddr string `default:"" validate:"hostport"`
+		// 优雅退出时等待处理中请求的最长时间
+		ShutdownTimeout config.Duration `default:"10s"`
+		// 为true时不监听SIGTERM/SIGINT, 由业务自行调用Shutdown
+		DisableSignal bool `default:"false"`
+		// 连接读写超时, 0表示不限制
+		ReadTimeout  config.Duration `default:"0s"`
+		WriteTimeout config.Duration `default:"0s"`
+		// Prometheus指标的HTTP监听地址, 如":9100", 为空不开启
+		MetricsAddr string `default:"" validate:"hostport"`
+		MetricsPath string `default:"/metrics"`
 	}
 }
 
+// defaultShutdownTimeout 未配置ShutdownTimeout时的默认等待时间
+const defaultShutdownTimeout = 10 * time.Second
+
 // v0.1.1
 type FLSvr struct {
 	s          *rpcx_svr.Server
@@ -30,94 +52,281 @@ type FLSvr struct {
 	consulAddr string
 	basePath   string
 	svrName    string
+
+	chainMu      sync.Mutex
+	interceptors []Interceptor
+	methods      []*method // 已注册的接口, Use时重新组装拦截器链
+	log          *fllog.Logger
+
+	reg        registry.Registry
+	regMu      sync.Mutex
+	registered bool
+
+	metricsSvr *http.Server
+
+	shutdownTimeout time.Duration
+	trapSignal      bool
+	shutdownOnce    sync.Once
+	shutdownErr     error
+	done            chan struct{}
 }
 
+// NewFLServer 同NewFLServerE, 配置错误时panic
 func NewFLServer(cfg string) *FLSvr {
+	flSvr, err := NewFLServerE(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return flSvr
+}
+
+// NewFLServerE 按配置文件创建服务, 配置不合法时返回列出所有错误字段的config.ValidationError
+func NewFLServerE(cfg string) (*FLSvr, error) {
 	if len(cfg) == 0 {
-		panic("cfg empty")
+		return nil, errors.New("cfg empty")
 	}
-	flSvr := &FLSvr{}
-	svrAddr, consulAddr, basePath, svrName, err := loadSvrCfgInfo(cfg)
+	flSvr := &FLSvr{done: make(chan struct{})}
+	svrCfg, basePath, svrName, err := loadSvrCfgInfo(cfg)
 	if err != nil {
-		panic("load svrcfg failed")
+		return nil, err
 	}
-	flSvr.svrAddr = svrAddr
-	flSvr.consulAddr = consulAddr
+	flSvr.svrAddr = svrCfg.Server.Address
+	flSvr.consulAddr = svrCfg.Server.ConsulAddr
 	flSvr.basePath = basePath
 	flSvr.svrName = svrName
-	flSvr.s = rpcx_svr.NewServer()
-	registerConuslPlugin(flSvr.s, svrAddr, consulAddr, basePath)
-	return flSvr
+	flSvr.log = logger.With(fllog.FieldService, svrName)
+	flSvr.shutdownTimeout = svrCfg.Server.ShutdownTimeout.Duration()
+	if flSvr.shutdownTimeout <= 0 {
+		flSvr.shutdownTimeout = defaultShutdownTimeout
+	}
+	flSvr.trapSignal = !svrCfg.Server.DisableSignal
+	// [Registry]未配置时沿用consul, 地址取Server.ConsulAddr
+	reg, err := registry.Load(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("load registry failed: %w", err)
+	}
+	// 同进程内的FlClient默认使用同一个注册中心
+	registry.SetDefault(reg)
+	flSvr.reg = reg
+	// 调用方的deadline由rpcx随请求元数据带过来, 业务函数通过ctx.Deadline()获得剩余时间
+	var options []rpcx_svr.OptionFn
+	if d := svrCfg.Server.ReadTimeout.Duration(); d > 0 {
+		options = append(options, rpcx_svr.WithReadTimeout(d))
+	}
+	if d := svrCfg.Server.WriteTimeout.Duration(); d > 0 {
+		options = append(options, rpcx_svr.WithWriteTimeout(d))
+	}
+	flSvr.s = rpcx_svr.NewServer(options...)
+	if len(svrCfg.Server.MetricsAddr) > 0 {
+		flSvr.metricsSvr = flmetrics.NewServer(svrCfg.Server.MetricsAddr, svrCfg.Server.MetricsPath)
+	}
+	return flSvr, nil
 }
 
+// RegisterHandler 注册svrHandle上所有符合rpcx签名的方法, 方法名=接口名
 func (f *FLSvr) RegisterHandler(svrHandle interface{}) error {
-	f.s.RegisterName(f.svrName, svrHandle, "")
-	fllog.Log().Debug("consulAddr:%s", consul.GetConsulAddr())
+	// 逐个方法包装成函数注册, 以便经过拦截器链
+	v := reflect.ValueOf(svrHandle)
+	t := v.Type()
+	num := 0
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if !method.IsExported() {
+			continue
+		}
+		fn := v.Method(i)
+		if !isHandlerFunc(fn.Type()) {
+			continue
+		}
+		serviceMethod := f.svrName + "." + method.Name
+		if err := f.s.RegisterFunctionName(f.svrName, method.Name, f.wrap(serviceMethod, fn), ""); err != nil {
+			f.log.Error("register method failed", fllog.FieldMethod, method.Name, "err", err)
+			return err
+		}
+		num++
+	}
+	if num == 0 {
+		return fmt.Errorf("type %s has no exported methods of suitable type", t)
+	}
+	f.log.Debug("register handler", "consulAddr", consul.GetConsulAddr(), "methods", num)
+
+	return f.register()
+}
+
+// RegisterFunc 注册接口函数, 函数名=接口名
+func (f *FLSvr) RegisterFunc(fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	if !v.IsValid() || v.Kind() != reflect.Func || v.IsNil() {
+		f.log.Error("register func failed, not a func", "type", fmt.Sprintf("%T", fn))
+		return fmt.Errorf("register func failed, %T is not a func", fn)
+	}
+	name := funcName(v)
+	if err := f.s.RegisterFunctionName(f.svrName, name, f.wrap(f.svrName+"."+name, v), ""); err != nil {
+		f.log.Error("register func failed", fllog.FieldMethod, name, "err", err)
+		return err
+	}
+	return f.register()
+}
+
+// isHandlerFunc 是否为 func(context.Context, *Args, *Reply) error 形式
+func isHandlerFunc(t reflect.Type) bool {
+	return t.NumIn() == 3 && t.NumOut() == 1 &&
+		t.In(0).Implements(typeOfContext) &&
+		t.In(2).Kind() == reflect.Ptr &&
+		t.Out(0) == typeOfError
+}
+
+// instance 本服务在注册中心中的节点
+func (f *FLSvr) instance() registry.Instance {
+	return registry.Instance{
+		BasePath: f.basePath,
+		SvrName:  f.svrName,
+		Addr:     "tcp@" + f.svrAddr,
+	}
+}
+
+// register 首次注册接口时把本服务注册到注册中心, 之后的接口共用同一个节点
+func (f *FLSvr) register() error {
+	f.regMu.Lock()
+	defer f.regMu.Unlock()
 
+	if f.registered {
+		return nil
+	}
+	if err := f.reg.Register(f.instance()); err != nil {
+		f.log.Error("register service failed", "addr", f.svrAddr, "err", err)
+		return err
+	}
+	f.registered = true
+	f.log.Debug("register service succ", "addr", f.svrAddr)
 	return nil
 }
 
-func (f *FLSvr) RegisterFunc(fn interface{}) {
-	f.s.RegisterFunction(f.svrName, fn, "")
+// deregister 从注册中心摘除本服务
+func (f *FLSvr) deregister() {
+	f.regMu.Lock()
+	defer f.regMu.Unlock()
+
+	if !f.registered {
+		return
+	}
+	if err := f.reg.Deregister(f.instance()); err != nil {
+		f.log.Error("deregister service failed", "addr", f.svrAddr, "err", err)
+	}
+	f.registered = false
 }
 
+// StartServer 启动服务并阻塞, 直到Serve失败或Shutdown完成
 func (f *FLSvr) StartServer() error {
+	if f.trapSignal {
+		go f.waitSignal()
+	}
+	if f.metricsSvr != nil {
+		go f.serveMetrics()
+	}
 	if err := f.s.Serve("tcp", f.svrAddr); err != nil {
-		fllog.Log().Error("serve failed. err:", err)
+		if errors.Is(err, rpcx_svr.ErrServerClosed) {
+			// 由Shutdown触发的退出, 等待摘流程走完再返回
+			<-f.done
+			return f.shutdownErr
+		}
+		f.log.Error("serve failed", "addr", f.svrAddr, "err", err)
 		return err
 	}
-	fllog.Log().Error("start server succ")
+	f.log.Info("server stopped", "addr", f.svrAddr)
 	return nil
 }
 
-func loadSvrCfgInfo(cfg string) (string, string, string, string, error) {
-	svrCfg := SvrCfg{}
-	if err := config.ParseConfigWithPath(&svrCfg, cfg); err != nil {
-		fllog.Log().Error("load svr logcfg failed.", err, cfg)
-		return "", "", "", "", err
+// serveMetrics 提供/metrics, 失败只记日志不影响RPC服务
+func (f *FLSvr) serveMetrics() {
+	f.log.Info("metrics listen", "addr", f.metricsSvr.Addr)
+	if err := f.metricsSvr.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		f.log.Error("metrics serve failed", "addr", f.metricsSvr.Addr, "err", err)
 	}
-	fllog.Log().Debug("svrCfg:%+v", svrCfg)
-	if len(svrCfg.Server.Name) == 0 || len(svrCfg.Server.Address) == 0 {
-		return "", "", "", "", errors.New("svrcfg invalid")
+}
+
+// Shutdown 优雅退出: 先从注册中心注销, 再停止accept新连接并等待处理中的请求完成,
+// 最长等到ctx超时, 最后关闭共享客户端并刷新日志. 可重复调用, 只会执行一次
+func (f *FLSvr) Shutdown(ctx context.Context) error {
+	f.shutdownOnce.Do(func() {
+		defer close(f.done)
+
+		// 先摘流量
+		f.deregister()
+
+		if err := f.s.Shutdown(ctx); err != nil {
+			f.log.Error("shutdown server failed", "err", err)
+			f.shutdownErr = err
+		}
+		// 处理中的请求已结束, 关闭共享的下游客户端
+		flcli.CloseAll()
+		if f.metricsSvr != nil {
+			_ = f.metricsSvr.Shutdown(ctx)
+		}
+		f.log.Info("server shutdown")
+		// 写完异步队列并关闭日志文件, stdout等输出Sync会报错, 忽略
+		_ = fllog.Close()
+	})
+	<-f.done
+	return f.shutdownErr
+}
+
+// waitSignal 收到SIGTERM/SIGINT后触发Shutdown
+func (f *FLSvr) waitSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(ch)
+
+	select {
+	case sig := <-ch:
+		f.log.Info("receive signal, shutting down", "signal", sig.String())
+	case <-f.done:
+		return
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), f.shutdownTimeout)
+	defer cancel()
+	f.Shutdown(ctx)
+}
+
+func loadSvrCfgInfo(cfg string) (SvrCfg, string, string, error) {
+	svrCfg := SvrCfg{}
+	sources, err := config.Load(&svrCfg, cfg)
+	var verrs config.ValidationError
+	if err != nil && !errors.As(err, &verrs) {
+		fllog.Error("load svrcfg failed. cfg:%s err:%v", cfg, err)
+		return svrCfg, "", "", err
+	}
+	// Name的格式无法用tag描述, 与tag校验的结果一起返回
 	basePath, svrName := parseSvrName(svrCfg.Server.Name)
-	if len(basePath) == 0 || len(svrName) == 0 {
-		fllog.Log().Error("basePath or svrName empty", basePath, svrName)
-		return "", "", "", "", errors.New("parse server name failed")
+	if (len(basePath) == 0 || len(svrName) == 0) && len(svrCfg.Server.Name) > 0 {
+		verrs = append(verrs, config.FieldError{Path: "Server.Name", Rule: "format",
+			Msg: fmt.Sprintf("want basePath.SvrName, got %q", svrCfg.Server.Name)})
+	}
+	if len(verrs) > 0 {
+		fllog.Error("svrcfg invalid. cfg:%s err:%v", cfg, verrs)
+		return svrCfg, "", "", verrs
+	}
+	if fllog.Allow("DEBUG") {
+		// 生效的配置及来源(default/file/env/flag)
+		var effective strings.Builder
+		config.Print(&effective, &svrCfg, sources)
+		fllog.Debug("effective svrcfg. cfg:%s\n%s", cfg, effective.String())
 	}
 
 	if len(svrCfg.Server.ConsulAddr) == 0 {
-		localIP := getLocalIp()
-		if len(localIP) == 0 {
-			fllog.Log().Error("localIP empty")
-			return "", "", "", "", errors.New("consulAddr empty")
+		// 非consul注册中心不需要该地址, 是否缺失由registry.Load判断
+		if localIP := getLocalIp(); len(localIP) > 0 {
+			svrCfg.Server.ConsulAddr = localIP + ":8500"
+		} else {
+			fllog.Error("localIP empty")
 		}
-		svrCfg.Server.ConsulAddr = localIP + ":8500"
 	}
-	fllog.Log().Debug(svrCfg.Server.Address, svrCfg.Server.ConsulAddr, basePath, svrName)
 	if len(svrCfg.Server.ConsulAddr) > 0 {
 		consul.SetConsulAddr(svrCfg.Server.ConsulAddr)
 	}
-	fllog.Log().Debug("svrCfg=", svrCfg)
-	return svrCfg.Server.Address, svrCfg.Server.ConsulAddr, basePath, svrName, nil
-}
-
-func registerConuslPlugin(s *rpcx_svr.Server, svrAddr, conuslAddr, basePath string) {
-	r := &cserver.ConsulRegisterPlugin{
-		ServiceAddress: "tcp@" + svrAddr,
-		ConsulServers:  []string{conuslAddr},
-		BasePath:       basePath,
-		Metrics:        metrics.NewRegistry(),
-		UpdateInterval: time.Minute,
-	}
-	err := r.Start()
-	if err != nil {
-		fllog.Log().Error("register consul failed. err=", err)
-	}
-
-	s.Plugins.Add(r)
-	fllog.Log().Debug("register consul succ!")
+	fllog.Debug("svrCfg:%+v basePath:%s svrName:%s", svrCfg, basePath, svrName)
+	return svrCfg, basePath, svrName, nil
 }
 
 func parseSvrName(name string) (string, string) {
@@ -131,24 +340,24 @@ func parseSvrName(name string) (string, string) {
 func getLocalIp() string {
 	iface, err := net.InterfaceByName("eth0")
 	if err != nil {
-		fllog.Log().Error("Error:", err)
+		fllog.Error("get local ip failed. err:%v", err)
 		return ""
 	}
 
 	addrs, err := iface.Addrs()
 	if err != nil {
-		fllog.Log().Error("Error:", err)
+		fllog.Error("get local ip failed. err:%v", err)
 		return ""
 	}
 
 	for _, addr := range addrs {
 		ip, _, err := net.ParseCIDR(addr.String())
 		if err != nil {
-			fllog.Log().Error("Error:", err)
+			fllog.Error("get local ip failed. err:%v", err)
 			continue
 		}
 		if ip.To4() != nil {
-			fllog.Log().Debug("IPv4:", ip)
+			fllog.Debug("local ip:%s", ip)
 			return ip.String()
 		}
 		//  else {
@@ -0,0 +1,115 @@
+package fltrace
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SpanData 导出的span数据
+type SpanData struct {
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind"`
+	TraceID       string            `json:"trace_id"`
+	SpanID        string            `json:"span_id"`
+	ParentSpanID  string            `json:"parent_span_id,omitempty"`
+	StartTime     time.Time         `json:"start_time"`
+	EndTime       time.Time         `json:"end_time"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	StatusCode    string            `json:"status_code"`
+	StatusMessage string            `json:"status_message,omitempty"`
+}
+
+// Exporter span导出接口, 方法与OpenTelemetry SpanExporter同形, 可包一层对接OTel SDK
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+var (
+	exporterMu sync.RWMutex
+	exporter   Exporter
+)
+
+// SetExporter 设置全局Exporter, nil表示不导出(仍会传播链路上下文)
+func SetExporter(e Exporter) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	exporter = e
+}
+
+// Shutdown 关闭全局Exporter, 进程退出前调用
+func Shutdown(ctx context.Context) error {
+	exporterMu.Lock()
+	e := exporter
+	exporter = nil
+	exporterMu.Unlock()
+	if e == nil {
+		return nil
+	}
+	return e.Shutdown(ctx)
+}
+
+func export(data SpanData) {
+	exporterMu.RLock()
+	e := exporter
+	exporterMu.RUnlock()
+	if e != nil {
+		_ = e.ExportSpans(context.Background(), []SpanData{data})
+	}
+}
+
+// WriterExporter 每个span一行JSON写到io.Writer, 用于本地调试
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterExporter 写到w的Exporter
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// NewStdoutExporter 写到标准输出的Exporter
+func NewStdoutExporter() *WriterExporter {
+	return NewWriterExporter(os.Stdout)
+}
+
+// NewFileExporter 追加写到文件的Exporter
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterExporter(f), nil
+}
+
+func (e *WriterExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.w == nil {
+		return nil
+	}
+	enc := json.NewEncoder(e.w)
+	for i := range spans {
+		if err := enc.Encode(&spans[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown 文件类输出会被关闭, 之后的span丢弃
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w := e.w
+	e.w = nil
+	if c, ok := w.(io.Closer); ok && w != io.Writer(os.Stdout) && w != io.Writer(os.Stderr) {
+		return c.Close()
+	}
+	return nil
+}
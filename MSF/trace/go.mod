@@ -0,0 +1,3 @@
+module github.com/xiaolongdeng1990/forlife/MSF/trace
+
+go 1.20
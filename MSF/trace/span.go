@@ -0,0 +1,123 @@
+package fltrace
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SpanKind span类型, 取值与OpenTelemetry一致
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Span 一次调用的耗时与结果, End后交给Exporter
+type Span struct {
+	mu    sync.Mutex
+	data  SpanData
+	sc    SpanContext
+	ended bool
+}
+
+// StartSpan 以ctx中的span或调用方传来的上下文为父节点创建span, 没有父节点时开启新链路
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+	if !parent.IsValid() {
+		sc.TraceID, sc.Sampled = newTraceID(), true
+	}
+	s := &Span{sc: sc, data: SpanData{
+		Name:      name,
+		Kind:      kind.String(),
+		TraceID:   sc.TraceID.String(),
+		SpanID:    sc.SpanID.String(),
+		StartTime: time.Now(),
+	}}
+	if parent.IsValid() {
+		s.data.ParentSpanID = parent.SpanID.String()
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanContext span的链路上下文
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetAttribute 追加属性
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]string)
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError 记录失败原因, err为nil时忽略
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.StatusCode = "error"
+	s.data.StatusMessage = err.Error()
+}
+
+// End 结束span并导出, 重复调用只生效一次
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	if len(s.data.StatusCode) == 0 {
+		s.data.StatusCode = "ok"
+	}
+	data := s.data
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		export(data)
+	}
+}
+
+// SpanFromContext 取ctx中当前进程创建的span, 没有返回nil
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemoteSpanContext 放入调用方传来的链路上下文, 作为之后StartSpan的父节点
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext 取ctx中的链路上下文, 本进程的span优先于调用方传来的
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
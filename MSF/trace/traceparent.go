@@ -0,0 +1,111 @@
+package fltrace
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	// TraceparentKey rpcx元数据中携带链路信息的key, 格式同W3C traceparent
+	TraceparentKey = "traceparent"
+	// RequestIDKey rpcx元数据中携带请求ID的key, 请求ID在入口生成后沿调用链透传
+	RequestIDKey = "request_id"
+)
+
+// ErrInvalidTraceparent traceparent格式非法
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceID 16字节链路ID
+type TraceID [16]byte
+
+// SpanID 8字节span ID
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (t TraceID) IsValid() bool  { return t != TraceID{} }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+func (s SpanID) IsValid() bool   { return s != SpanID{} }
+
+// SpanContext 跨进程传递的链路上下文
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid TraceID与SpanID都非零
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent 按W3C格式输出, 如 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent 解析W3C traceparent, 未知version按00的格式解析前4段
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// Inject 把ctx中的链路上下文写入请求元数据
+func Inject(ctx context.Context, meta map[string]string) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() && meta != nil {
+		meta[TraceparentKey] = sc.Traceparent()
+	}
+}
+
+// Extract 从请求元数据取出调用方的链路上下文放入ctx, 没有或非法时原样返回
+func Extract(ctx context.Context, meta map[string]string) context.Context {
+	sc, err := ParseTraceparent(meta[TraceparentKey])
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// NewRequestID 生成16位十六进制的请求ID
+func NewRequestID() string {
+	return newSpanID().String()
+}
+
+func newTraceID() (id TraceID) {
+	_, _ = crand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	for !id.IsValid() {
+		_, _ = crand.Read(id[:])
+	}
+	return id
+}
@@ -1,6 +1,6 @@
 module github.com/xiaolongdeng1990/forlife/application/demo
 
-go 1.20
+go 1.21
 
 require (
 	github.com/xiaolongdeng1990/forlife/MSF/log v0.0.0-20240427031104-4be65f191e68
@@ -8,6 +8,37 @@ require (
 	github.com/xiaolongdeng1990/forlife/protocol/json/math v0.0.0-20240427031632-94cbae449b38
 )
 
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cenk/backoff v2.2.1+incompatible // indirect
+	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-jump v0.0.0-20211018200510-ba001c3ffce0 // indirect
+	github.com/edwingeng/doublejump v1.0.1 // indirect
+	github.com/facebookgo/clock v0.0.0-20150410010913-600d898af40a // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/go-ping/ping v1.1.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/grandcat/zeroconf v1.0.0 // indirect
+	github.com/hashicorp/errwrap v1.1.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/juju/ratelimit v1.0.2 // indirect
+	github.com/miekg/dns v1.1.51 // indirect
+	github.com/prometheus/client_golang v1.19.1 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	github.com/rubyist/circuitbreaker v2.2.1+incompatible // indirect
+	github.com/valyala/fastrand v1.1.0 // indirect
+	github.com/xiaolongdeng1990/forlife/MSF/client v0.0.0-00010101000000-000000000000 // indirect
+	github.com/xiaolongdeng1990/forlife/MSF/errors v0.0.0-00010101000000-000000000000 // indirect
+	github.com/xiaolongdeng1990/forlife/MSF/metrics v0.0.0-00010101000000-000000000000 // indirect
+	github.com/xiaolongdeng1990/forlife/MSF/registry v0.0.0-00010101000000-000000000000 // indirect
+	github.com/xiaolongdeng1990/forlife/MSF/trace v0.0.0-00010101000000-000000000000 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
 require (
 	github.com/BurntSushi/toml v1.3.2 // indirect
 	github.com/akutz/memconn v0.1.0 // indirect
@@ -29,7 +60,6 @@ require (
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
 	github.com/hashicorp/serf v0.9.8 // indirect
 	github.com/jamiealquiza/tachymeter v2.0.0+incompatible // indirect
-	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/julienschmidt/httprouter v1.3.0 // indirect
 	github.com/kavu/go_reuseport v1.5.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.4 // indirect
@@ -39,8 +69,6 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
-	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
-	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/onsi/ginkgo/v2 v2.9.5 // indirect
 	github.com/philhofer/fwd v1.1.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
@@ -50,7 +78,7 @@ require (
 	github.com/rpcxio/rpcx-consul v0.0.0-20230904043151-f6175fbe2f72 // indirect
 	github.com/rs/cors v1.8.3 // indirect
 	github.com/smallnest/quick v0.1.0 // indirect
-	github.com/smallnest/rpcx v1.8.29 // indirect
+	github.com/smallnest/rpcx v1.8.30 // indirect
 	github.com/smallnest/statsview v0.0.0-20231119085602-10700f9abec4 // indirect
 	github.com/soheilhy/cmux v0.1.5 // indirect
 	github.com/templexxx/cpufeat v0.0.0-20180724012125-cef66df7f161 // indirect
@@ -59,20 +87,32 @@ require (
 	github.com/tjfoc/gmsm v1.4.1 // indirect
 	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
-	github.com/wk8/go-ordered-map v1.0.0 // indirect
-	github.com/xiaolongdeng1990/forlife/MSF/config v0.0.0-20240423133039-064980ade61d // indirect
-	github.com/xiaolongdeng1990/forlife/MSF/consul v0.0.0-20240426124830-4efd920762d7 // indirect
+	github.com/xiaolongdeng1990/forlife/MSF/config v0.0.0-20240423133039-064980ade61d
+	github.com/xiaolongdeng1990/forlife/MSF/consul v0.0.0-20240427023951-cd5e012ea9d6 // indirect
 	github.com/xtaci/kcp-go v5.4.20+incompatible // indirect
 	go.uber.org/mock v0.4.0 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
-	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/crypto v0.18.0 // indirect
 	golang.org/x/exp v0.0.0-20230307190834-24139beb5833 // indirect
 	golang.org/x/mod v0.11.0 // indirect
-	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	golang.org/x/tools v0.9.1 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
 )
+
+replace (
+	github.com/xiaolongdeng1990/forlife/MSF/client => ../../MSF/client
+	github.com/xiaolongdeng1990/forlife/MSF/config => ../../MSF/config
+	github.com/xiaolongdeng1990/forlife/MSF/consul => ../../MSF/consul
+	github.com/xiaolongdeng1990/forlife/MSF/errors => ../../MSF/errors
+	github.com/xiaolongdeng1990/forlife/MSF/log => ../../MSF/log
+	github.com/xiaolongdeng1990/forlife/MSF/metrics => ../../MSF/metrics
+	github.com/xiaolongdeng1990/forlife/MSF/registry => ../../MSF/registry
+	github.com/xiaolongdeng1990/forlife/MSF/server => ../../MSF/server
+	github.com/xiaolongdeng1990/forlife/MSF/trace => ../../MSF/trace
+	github.com/xiaolongdeng1990/forlife/protocol/json/math => ../../protocol/json/math
+)
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 
+	"github.com/xiaolongdeng1990/forlife/MSF/config"
 	fllog "github.com/xiaolongdeng1990/forlife/MSF/log"
 	flsvr "github.com/xiaolongdeng1990/forlife/MSF/server"
 
@@ -18,25 +19,25 @@ var (
 
 func init() {
 	flag.StringVar(&cfg, "c", "../conf/rpcx_demo.toml", "config file path, default ../conf/rpcx_demo.toml")
+	// -set Server.Address=:8080 覆盖配置文件与环境变量
+	config.RegisterFlags(flag.CommandLine)
 }
 
 func Mul(ctx context.Context, args *math.Args, reply *math.Reply) error {
 	reply.C = args.A * args.B
-	fllog.Log().Debug("req=", args, "reply=", reply)
 	return nil
 }
 
 func Add(ctx context.Context, args *math.Args, reply *math.Reply) error {
 	reply.C = args.A + args.B
-	fllog.Log().Debug("req=", args, "reply=", reply)
 
 	// client rpc demo
 	// callDesc := flcli.CallDesc{
 	// 	ServiceName: "/rpcx_test.Demo.Add", // rpcx_test = basePath; Demo = svrName; Add = 接口名
 	// 	Timeout:     time.Second,
 	// }
-	// flC := flcli.NewClient(callDesc)
-	// defer flC.Close()
+	// flC := flcli.GetClient(callDesc) // 按ServiceName共享, 不必每次请求创建
+	// defer flC.Release()
 
 	// flC.DoRequest(context.Background(), args, reply)
 	return nil
@@ -51,9 +52,15 @@ func main() {
 	}
 	// config init.
 
-	fllog.Log().Debug("test fllog debug cfg:", cfg)
+	fllog.With("cfg", cfg).Debug("test fllog debug")
 	// server init
-	svr := flsvr.NewFLServer(cfg)
+	svr, err := flsvr.NewFLServerE(cfg)
+	if err != nil {
+		fmt.Printf("server init failed. err:%+v", err)
+		return
+	}
+	// 统一的panic恢复、参数校验与访问日志, 各接口无需再自行打印
+	svr.Use(flsvr.Recovery(), flsvr.AccessLog(), flsvr.Validate())
 	svr.RegisterFunc(Mul) // 注册接口函数，函数名=接口名
 	svr.RegisterFunc(Add)
 	svr.StartServer()